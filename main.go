@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	_ "net/http/pprof"
+	"time"
 
+	"github.com/rancher/helm-locker/pkg/controllers/release"
+	"github.com/rancher/helm-locker/pkg/objectset"
 	"github.com/rancher/helm-locker/pkg/operator"
 	_ "github.com/rancher/wrangler/v3/pkg/generated/controllers/apiextensions.k8s.io"
 	_ "github.com/rancher/wrangler/v3/pkg/generated/controllers/networking.k8s.io"
@@ -19,6 +22,21 @@ func BuildHelmLockerCommand() *cobra.Command {
 	var controllerName string
 	var nodeName string
 	var pprofEnabled bool
+	var notifierConfigMap string
+	var notifierWebhookURLs []string
+	var notifierCloudEventsURLs []string
+	var notifierSlackURLs []string
+	var policyReportsEnabled bool
+	var conflictPolicy string
+	var applyMode string
+	var fieldManager string
+	var flapThreshold int
+	var flapWindow time.Duration
+	var recreateKinds []string
+	var purgeGracePeriod time.Duration
+	var maxPurgeObjects int
+	var apiVersionMappings []string
+	var metricsAddress string
 	viper.AutomaticEnv()
 	cmd := &cobra.Command{
 		Use: "helm-locker",
@@ -30,6 +48,29 @@ func BuildHelmLockerCommand() *cobra.Command {
 				NodeName:       nodeName,
 				ClientConfig:   cfg,
 				PprofEnabled:   pprofEnabled,
+
+				NotifierConfigMap:       notifierConfigMap,
+				NotifierWebhookURLs:     notifierWebhookURLs,
+				NotifierCloudEventsURLs: notifierCloudEventsURLs,
+				NotifierSlackURLs:       notifierSlackURLs,
+
+				PolicyReportsEnabled: policyReportsEnabled,
+
+				ConflictPolicy: conflictPolicy,
+				ApplyMode:      applyMode,
+				FieldManager:   fieldManager,
+
+				FlapThreshold: flapThreshold,
+				FlapWindow:    flapWindow,
+
+				RecreateKinds: recreateKinds,
+
+				PurgeGracePeriod: purgeGracePeriod,
+				MaxPurgeObjects:  maxPurgeObjects,
+
+				APIVersionMappings: apiVersionMappings,
+
+				MetricsAddress: metricsAddress,
 			}
 			if err := operator.Run(cmd.Context(), options); err != nil {
 				return err
@@ -43,6 +84,21 @@ func BuildHelmLockerCommand() *cobra.Command {
 	flags.StringVar(&controllerName, "controller-name", "helm-locker", "Unique name to identify this controller that is added to all HelmReleases tracked by this controller")
 	flags.StringVar(&nodeName, "node-name", "", "Name of the node this controller is running on")
 	flags.BoolVarP(&pprofEnabled, "pprof", "p", false, "flag to enable pprof on port 6060")
+	flags.StringVar(&notifierConfigMap, "notifier-configmap", "", "Name of a ConfigMap in the namespace provided that configures sinks notified when drift is reverted")
+	flags.StringSliceVar(&notifierWebhookURLs, "notify-webhook-url", nil, "URL that is sent a JSON payload when drift is reverted (can be repeated)")
+	flags.StringSliceVar(&notifierCloudEventsURLs, "notify-cloudevents-url", nil, "URL that is sent a CloudEvent when drift is reverted (can be repeated)")
+	flags.StringSliceVar(&notifierSlackURLs, "notify-slack-url", nil, "Slack-compatible incoming webhook URL that is sent a message when drift is reverted (can be repeated)")
+	flags.StringVar(&conflictPolicy, "conflict-policy", string(objectset.DefaultConflictPolicy), "Policy used to decide which release locks an object tracked by more than one release (first-wins, newest-wins, or refuse)")
+	flags.StringVar(&applyMode, "apply-mode", string(objectset.DefaultApplyMode), "Mode used to revert changes to locked objects (client-side, or server-side to only own and revert fields in the Helm release)")
+	flags.StringVar(&fieldManager, "field-manager", objectset.DefaultFieldManager, "Field manager used to revert changes to locked objects in server-side apply mode")
+	flags.IntVar(&flapThreshold, "flap-threshold", objectset.DefaultFlapThreshold, "Number of times an object can be reverted within the flap window before it is no longer reverted until it stops changing (default 0, which disables flap detection)")
+	flags.DurationVar(&flapWindow, "flap-window", objectset.DefaultFlapWindow, "Window within which reverts of an object are counted towards the flap threshold")
+	flags.StringSliceVar(&recreateKinds, "recreate-kinds", nil, "Kinds of objects (e.g. Service or Job.batch) that are deleted and re-created if reverting changes to them fails due to immutable fields, or * for any kind (can be repeated)")
+	flags.DurationVar(&purgeGracePeriod, "purge-grace-period", release.DefaultPurgeGracePeriod, "Time that a Helm release secret must not be found for before the objects of the release are purged (0 purges them as soon as it is not found)")
+	flags.IntVar(&maxPurgeObjects, "max-purge-objects", 0, "Maximum number of objects of a Helm release that are purged without confirmation by annotation (0 disables the limit)")
+	flags.StringSliceVar(&apiVersionMappings, "api-version-mappings", nil, "Mappings of apiVersions in release manifests that are not served by the cluster to served ones as Kind.version.group=group/version (e.g. Ingress.v1beta1.extensions=networking.k8s.io/v1), on top of built-in mappings for apiVersions removed from Kubernetes (can be repeated)")
+	flags.StringVar(&metricsAddress, "metrics-address", "", "Address to serve Prometheus metrics on (e.g. :8080); metrics are not served if not provided")
+	flags.BoolVar(&policyReportsEnabled, "policy-reports", false, "flag to publish the results of locking releases as wg-policy PolicyReports in each release namespace")
 
 	viper.BindPFlag("kubeconfig", flags.Lookup("KUBECONFIG"))
 	viper.BindPFlag("namespace", flags.Lookup("NAMESPACE"))
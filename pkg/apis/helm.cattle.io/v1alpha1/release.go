@@ -30,6 +30,69 @@ const (
 	TransitioningState = "Transitioning"
 )
 
+const (
+	// Helm Release Deletion Policies
+
+	// PurgeDeletionPolicy purges the objects of a Helm release once its release secret has not been found for the purge grace period
+	PurgeDeletionPolicy = "Purge"
+
+	// OrphanDeletionPolicy leaves the objects of a Helm release in the cluster if its release secret is not found
+	OrphanDeletionPolicy = "Orphan"
+
+	// ConfirmByAnnotationDeletionPolicy only purges the objects of a Helm release whose release secret is not found once the
+	// HelmRelease is annotated to confirm the purge
+	ConfirmByAnnotationDeletionPolicy = "ConfirmByAnnotation"
+)
+
+const (
+	// Stray Object Policies
+
+	// ReportStrayObjectPolicy reports objects that claim to belong to a Helm release but are not in its manifest
+	ReportStrayObjectPolicy = "Report"
+
+	// PruneStrayObjectPolicy deletes objects that claim to belong to a Helm release but are not in its manifest
+	PruneStrayObjectPolicy = "Prune"
+)
+
+const (
+	// Helm Release Conditions
+
+	// HealthyCondition is the condition that reports the aggregated health of the objects locked by a HelmRelease
+	HealthyCondition = "Healthy"
+
+	// ConflictedCondition is the condition that reports objects a HelmRelease cannot lock since they are locked by another HelmRelease
+	ConflictedCondition = "Conflicted"
+
+	// ManifestParsedCondition is the condition that reports whether every object in the manifest of a Helm release could be parsed
+	ManifestParsedCondition = "ManifestParsed"
+
+	// APIVersionsServedCondition is the condition that reports objects in the manifest of a Helm release that are not locked
+	// since their apiVersion is not served by the cluster
+	APIVersionsServedCondition = "APIVersionsServed"
+
+	// StrayObjectsCondition is the condition that reports objects that claim to belong to a Helm release but are not in its manifest
+	StrayObjectsCondition = "StrayObjects"
+
+	// PurgePendingCondition is the condition that reports that the objects of a Helm release whose release secret was not found will be purged
+	PurgePendingCondition = "PurgePending"
+)
+
+const (
+	// Locked Object States
+
+	// LockedObjectState is the state of an object that is kept locked into place
+	LockedObjectState = "Locked"
+
+	// UnlockedObjectState is the state of an object whose Helm release is not deployed, so changes to it are allowed
+	UnlockedObjectState = "Unlocked"
+
+	// ContestedObjectState is the state of an object that was reverted too often and is no longer being reverted
+	ContestedObjectState = "Contested"
+
+	// UnenforceableObjectState is the state of an object that never matched the Helm release after being reverted and is no longer being reverted
+	UnenforceableObjectState = "Unenforceable"
+)
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -42,6 +105,59 @@ type HelmRelease struct {
 
 type HelmReleaseSpec struct {
 	Release ReleaseKey `json:"release,omitempty"`
+
+	// FlapDetection overrides when objects that keep being modified by another actor are no longer reverted
+	FlapDetection *FlapDetection `json:"flapDetection,omitempty"`
+	// Recreate configures which objects are deleted and re-created if reverting changes to them fails, e.g. since an immutable field was changed
+	Recreate *Recreate `json:"recreate,omitempty"`
+	// IncludeHooks locks the resources created by hooks of the Helm release that are kept in the cluster, excluding test hooks
+	// and hooks whose resources are deleted once they succeed or fail
+	IncludeHooks bool `json:"includeHooks,omitempty"`
+	// LockCRDs locks the CustomResourceDefinitions in the crds/ directory of the chart, which are never purged
+	LockCRDs bool `json:"lockCRDs,omitempty"`
+	// Subcharts selects the subcharts of an umbrella chart whose objects are locked; by default, every object is locked
+	Subcharts *Subcharts `json:"subcharts,omitempty"`
+	// StrayObjects enables strict mode, which reports or prunes objects that carry the Helm ownership labels and annotations
+	// of the Helm release but are not in its manifest
+	StrayObjects *StrayObjects `json:"strayObjects,omitempty"`
+	// DeletionPolicy determines what happens to the objects of the Helm release if its release secret is not found (Purge, Orphan, or ConfirmByAnnotation)
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// FlapDetection configures when an object that keeps being modified by another actor is no longer reverted
+type FlapDetection struct {
+	// Threshold is the number of times an object can be reverted within the window before it is no longer reverted; 0 disables flap detection
+	Threshold *int `json:"threshold,omitempty"`
+	// Window is the duration within which reverts of an object are counted (e.g. 5m)
+	Window string `json:"window,omitempty"`
+}
+
+// Recreate configures which objects are deleted and re-created if reverting changes to them fails
+type Recreate struct {
+	// All re-creates objects of any kind
+	All bool `json:"all,omitempty"`
+	// Kinds are the kinds of objects that are re-created, provided as Kind (e.g. Service) or Kind.group (e.g. Job.batch)
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+// Subcharts selects subcharts of the chart by name (e.g. security for objects rendered by umbrella/charts/security/templates)
+//
+// Objects rendered by the templates of the chart itself belong to no subchart, so they are only locked if Include is empty.
+// Objects rendered by nested subcharts belong to every subchart that contains them (e.g. security and nested for
+// umbrella/charts/security/charts/nested/templates), so they are locked if any of them is included and none is excluded.
+type Subcharts struct {
+	// Include are the subcharts whose objects are locked; if empty, the objects of every subchart that is not excluded are locked
+	Include []string `json:"include,omitempty"`
+	// Exclude are the subcharts whose objects are not locked
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// StrayObjects configures how objects that claim to belong to a Helm release but are not in its manifest are handled
+type StrayObjects struct {
+	// Policy determines whether stray objects are reported (Report) or deleted (Prune); defaults to Report
+	Policy string `json:"policy,omitempty"`
+	// DryRun only reports the stray objects that the Prune policy would delete
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 type ReleaseKey struct {
@@ -56,4 +172,90 @@ type HelmReleaseStatus struct {
 	Notes       string `json:"notes,omitempty"`
 
 	Conditions []genericcondition.GenericCondition `json:"conditions,omitempty"`
+
+	// PurgeAfter is the time after which the objects of the Helm release are purged since its release secret was not found
+	PurgeAfter string `json:"purgeAfter,omitempty"`
+
+	// UnhealthyObjects are the objects locked by this HelmRelease that are not healthy
+	UnhealthyObjects []ObjectHealth `json:"unhealthyObjects,omitempty"`
+
+	// Inventory lists the objects tracked by this HelmRelease
+	Inventory Inventory `json:"inventory,omitempty"`
+
+	// DriftCorrections is the total number of times objects tracked by this HelmRelease were reverted
+	DriftCorrections int64 `json:"driftCorrections,omitempty"`
+	// LastDriftCorrection is the last time an object tracked by this HelmRelease was reverted
+	LastDriftCorrection string `json:"lastDriftCorrection,omitempty"`
+	// DriftHistory are the most recent drift corrections made to objects tracked by this HelmRelease, oldest first
+	DriftHistory []DriftRecord `json:"driftHistory,omitempty"`
+}
+
+// DriftRecord describes a drift correction made to an object tracked by a HelmRelease
+type DriftRecord struct {
+	ObjectReference `json:",inline"`
+
+	// Time is the time the object was reverted
+	Time string `json:"time,omitempty"`
+	// Actor is the field manager that last modified the object before it was reverted, if known
+	Actor string `json:"actor,omitempty"`
+	// DiffDigest is a digest of the changes that were reverted
+	DiffDigest string `json:"diffDigest,omitempty"`
+	// Source is the template that rendered the object (e.g. chart/templates/configmap.yaml), if known
+	Source string `json:"source,omitempty"`
+}
+
+// Inventory lists the objects tracked by a HelmRelease
+//
+// To bound the size of a HelmRelease, only one of Objects, Compressed, or ConfigMap is set
+type Inventory struct {
+	// Count is the number of objects tracked by the HelmRelease
+	Count int `json:"count,omitempty"`
+	// Objects are the objects tracked by the HelmRelease, if they fit in the status uncompressed
+	Objects []InventoryEntry `json:"objects,omitempty"`
+	// Compressed is the base64-encoded gzipped JSON list of objects tracked by the HelmRelease, if they only fit in the status compressed
+	Compressed string `json:"compressed,omitempty"`
+	// ConfigMap is the name of a ConfigMap in the namespace of the HelmRelease that contains the JSON list of objects tracked by the HelmRelease
+	ConfigMap string `json:"configMap,omitempty"`
+	// Kinds are the kinds of objects that have been tracked by the HelmRelease, whose objects are purged if the Helm release is deleted
+	Kinds []TypeReference `json:"kinds,omitempty"`
+}
+
+// TypeReference identifies a kind of object
+type TypeReference struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+}
+
+// InventoryEntry describes an object tracked by a HelmRelease
+type InventoryEntry struct {
+	ObjectReference `json:",inline"`
+
+	// Source is the template that rendered the object (e.g. chart/templates/configmap.yaml), if known
+	Source string `json:"source,omitempty"`
+	// State is the lock state of the object
+	State string `json:"state,omitempty"`
+	// Corrections is the number of times the object was reverted since the Helm release was installed or upgraded
+	Corrections int `json:"corrections,omitempty"`
+	// LastCorrected is the last time the object was reverted
+	LastCorrected string `json:"lastCorrected,omitempty"`
+	// DivergentPaths are the field paths that did not match the Helm release the last time the object was reverted
+	DivergentPaths []string `json:"divergentPaths,omitempty"`
+}
+
+// ObjectReference identifies an object tracked by a Helm release
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// ObjectHealth is the health of an object tracked by a Helm release
+type ObjectHealth struct {
+	ObjectReference `json:",inline"`
+
+	// Status is the kstatus-style health of the object (Current, InProgress, or Failed)
+	Status string `json:"status,omitempty"`
+	// Message explains the Status of the object
+	Message string `json:"message,omitempty"`
 }
@@ -5,10 +5,15 @@ import (
 	"errors"
 	"time"
 
+	"github.com/rancher/helm-locker/pkg/controllers/notifier"
+	"github.com/rancher/helm-locker/pkg/controllers/policyreport"
 	"github.com/rancher/helm-locker/pkg/controllers/release"
 	"github.com/rancher/helm-locker/pkg/generated/controllers/helm.cattle.io"
 	helmcontroller "github.com/rancher/helm-locker/pkg/generated/controllers/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/health"
+	notifierpkg "github.com/rancher/helm-locker/pkg/notifier"
 	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/helm-locker/pkg/objectset/parser"
 	"github.com/rancher/lasso/pkg/cache"
 	"github.com/rancher/lasso/pkg/client"
 	"github.com/rancher/lasso/pkg/controller"
@@ -22,10 +27,15 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/start"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -34,10 +44,15 @@ import (
 type appContext struct {
 	helmcontroller.Interface
 
-	K8s  kubernetes.Interface
-	Core corecontroller.Interface
+	K8s        kubernetes.Interface
+	Dynamic    dynamic.Interface
+	RESTMapper meta.RESTMapper
+	Core       corecontroller.Interface
+	// SystemCore only caches core resources in the system namespace
+	SystemCore corecontroller.Interface
 
-	Apply apply.Apply
+	Apply                   apply.Apply
+	SharedControllerFactory controller.SharedControllerFactory
 
 	ObjectSetRegister objectset.LockableRegister
 	ObjectSetHandler  *controller.SharedHandler
@@ -51,19 +66,34 @@ func (a *appContext) start(ctx context.Context) error {
 	return start.All(ctx, 50, a.starters...)
 }
 
-func Register(ctx context.Context, systemNamespace, controllerName, nodeName string, cfg clientcmd.ClientConfig) error {
+// Options are optional settings for the controllers registered by Register
+type Options struct {
+	// Notifier configures the sinks that are notified when drift is reverted on locked releases
+	Notifier notifierpkg.Options
+	// PolicyReports enables maintaining wg-policy PolicyReports for locked releases
+	PolicyReports bool
+	// Lock configures how the objects of Helm releases are locked
+	Lock objectset.LockOptions
+	// Purge configures when the objects of Helm releases whose release secret was not found are purged
+	Purge release.PurgeOptions
+	// APIVersionMappings rewrite objects in release manifests whose apiVersion is not served by the cluster to one that is
+	APIVersionMappings parser.APIVersionMappings
+}
+
+func Register(ctx context.Context, systemNamespace, controllerName, nodeName string, cfg clientcmd.ClientConfig, opts Options) error {
 	if len(systemNamespace) == 0 {
 		return errors.New("cannot start controllers on system namespace: system namespace not provided")
 	}
 
-	appCtx, err := newContext(ctx, systemNamespace, cfg)
+	appCtx, err := newContext(ctx, systemNamespace, cfg, opts)
 	if err != nil {
 		return err
 	}
 
 	appCtx.EventBroadcaster.StartLogging(logrus.Debugf)
+	// events are recorded in the namespace of the object they are emitted on, which may be outside the system namespace
 	appCtx.EventBroadcaster.StartRecordingToSink(&typedv1.EventSinkImpl{
-		Interface: appCtx.K8s.CoreV1().Events(systemNamespace),
+		Interface: appCtx.K8s.CoreV1().Events(metav1.NamespaceAll),
 	})
 	recorder := appCtx.EventBroadcaster.NewRecorder(schemes.All, corev1.EventSource{
 		Component: "helm-locker",
@@ -82,12 +112,35 @@ func Register(ctx context.Context, systemNamespace, controllerName, nodeName str
 		appCtx.HelmRelease().Cache(),
 		appCtx.Core.Secret(),
 		appCtx.Core.Secret().Cache(),
+		appCtx.Core.ConfigMap(),
 		appCtx.K8s,
+		appCtx.RESTMapper,
+		opts.APIVersionMappings,
 		appCtx.ObjectSetRegister,
 		appCtx.ObjectSetHandler,
+		opts.Lock.FlapDetection,
+		opts.Purge,
+		health.NewChecker(appCtx.SharedControllerFactory),
 		recorder,
 	)
 
+	if err := notifier.Register(ctx,
+		systemNamespace,
+		opts.Notifier,
+		appCtx.SystemCore.ConfigMap(),
+		appCtx.ObjectSetHandler,
+	); err != nil {
+		return err
+	}
+
+	if opts.PolicyReports {
+		policyreport.Register(ctx,
+			appCtx.Dynamic,
+			appCtx.RESTMapper,
+			appCtx.ObjectSetHandler,
+		)
+	}
+
 	leader.RunOrDie(ctx, systemNamespace, "helm-locker-lock", appCtx.K8s, func(ctx context.Context) {
 		if err := appCtx.start(ctx); err != nil {
 			logrus.Fatal(err)
@@ -112,7 +165,7 @@ func controllerFactory(rest *rest.Config) (controller.SharedControllerFactory, e
 	}), nil
 }
 
-func newContext(_ context.Context, systemNamespace string, cfg clientcmd.ClientConfig) (*appContext, error) {
+func newContext(_ context.Context, systemNamespace string, cfg clientcmd.ClientConfig, opts Options) (*appContext, error) {
 	client, err := cfg.ClientConfig()
 	if err != nil {
 		return nil, err
@@ -124,6 +177,11 @@ func newContext(_ context.Context, systemNamespace string, cfg clientcmd.ClientC
 		return nil, err
 	}
 
+	dynamic, err := dynamic.NewForConfig(client)
+	if err != nil {
+		return nil, err
+	}
+
 	discovery, err := discovery.NewDiscoveryClientForConfig(client)
 	if err != nil {
 		return nil, err
@@ -134,6 +192,15 @@ func newContext(_ context.Context, systemNamespace string, cfg clientcmd.ClientC
 		return nil, err
 	}
 
+	// the notifier ConfigMap is only read from the system namespace, so it is cached separately from the
+	// ConfigMaps of locked releases to avoid caching every ConfigMap in the cluster for it
+	systemCore, err := core.NewFactoryFromConfigWithOptions(client, &generic.FactoryOptions{
+		Namespace: systemNamespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	core, err := core.NewFactoryFromConfigWithOptions(client, &generic.FactoryOptions{
 		SharedControllerFactory: scf,
 	})
@@ -153,15 +220,19 @@ func newContext(_ context.Context, systemNamespace string, cfg clientcmd.ClientC
 
 	apply := apply.New(discovery, apply.NewClientFactory(client))
 
-	objectSet, objectSetRegister, objectSetHandler := objectset.NewLockableRegister("object-set-register", apply, scf, discovery, nil)
+	objectSet, objectSetRegister, objectSetHandler := objectset.NewLockableRegister("object-set-register", apply, scf, discovery, opts.Lock, nil)
 
 	return &appContext{
 		Interface: helmv,
 
-		K8s:  k8s,
-		Core: corev,
+		K8s:        k8s,
+		Dynamic:    dynamic,
+		RESTMapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discovery)),
+		Core:       corev,
+		SystemCore: systemCore.Core().V1(),
 
-		Apply: apply,
+		Apply:                   apply,
+		SharedControllerFactory: scf,
 
 		ObjectSetRegister: objectSetRegister,
 		ObjectSetHandler:  objectSetHandler,
@@ -171,6 +242,7 @@ func newContext(_ context.Context, systemNamespace string, cfg clientcmd.ClientC
 		starters: []start.Starter{
 			objectSet,
 			core,
+			systemCore,
 			helm,
 		},
 	}, nil
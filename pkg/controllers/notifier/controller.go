@@ -0,0 +1,130 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rancher/helm-locker/pkg/notifier"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/lasso/pkg/controller"
+	corecontroller "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/v3/pkg/gvk"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type handler struct {
+	ctx             context.Context
+	systemNamespace string
+	options         notifier.Options
+
+	notifier *notifier.Notifier
+
+	// config is the contents of the notifier ConfigMap that the notifier was last configured with
+	config string
+	// configLock is a lock on config
+	configLock sync.Mutex
+}
+
+// Register sends notifications to the sinks configured in the provided options whenever an ObjectSet
+// tracked by the lockableObjectSetHandler reverts drift on any of its objects
+func Register(
+	ctx context.Context,
+	systemNamespace string,
+	options notifier.Options,
+	configMaps corecontroller.ConfigMapController,
+	lockableObjectSetHandler *controller.SharedHandler,
+) error {
+	h := &handler{
+		ctx:             ctx,
+		systemNamespace: systemNamespace,
+		options:         options,
+
+		notifier: notifier.New(),
+	}
+
+	if err := h.notifier.Configure(options.Sinks); err != nil {
+		return err
+	}
+
+	if len(options.ConfigMapName) > 0 {
+		configMaps.OnChange(ctx, "configure-notifier", h.OnConfigMapChange)
+	}
+
+	lockableObjectSetHandler.Register(ctx, "notify-on-objectset-change", controller.SharedControllerHandlerFunc(h.OnObjectSetChange))
+
+	return nil
+}
+
+// OnConfigMapChange reconfigures the notifier on changes to the notifier ConfigMap
+func (h *handler) OnConfigMapChange(key string, configMap *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if key != h.systemNamespace+"/"+h.options.ConfigMapName {
+		return configMap, nil
+	}
+	var data string
+	if configMap != nil && configMap.DeletionTimestamp == nil {
+		data = configMap.Data[notifier.ConfigKey]
+	}
+	h.configLock.Lock()
+	defer h.configLock.Unlock()
+	if data == h.config {
+		// reconfiguring the notifier resets the deduplication and rate limits of its sinks, so it is only done on changes
+		return configMap, nil
+	}
+	sinks := h.options.Sinks
+	if len(data) > 0 {
+		config, err := notifier.ParseConfig(data)
+		if err != nil {
+			// an invalid configuration will not become valid on retrying, so keep the last valid configuration
+			logrus.Errorf("ignoring invalid notifier configuration in ConfigMap %s: %s", key, err)
+			return configMap, nil
+		}
+		sinks = append(append([]notifier.SinkConfig{}, sinks...), config.Sinks...)
+	}
+	logrus.Infof("configuring notifier with %d sink(s)", len(sinks))
+	if err := h.notifier.Configure(sinks); err != nil {
+		return configMap, err
+	}
+	h.config = data
+	return configMap, nil
+}
+
+// OnObjectSetChange notifies sinks of any objects that were reverted on applying an ObjectSet
+func (h *handler) OnObjectSetChange(setID string, obj runtime.Object) (runtime.Object, error) {
+	applied, ok := obj.(objectset.Applied)
+	if !ok || len(applied.Reverted()) == 0 {
+		return nil, nil
+	}
+	releaseKey := relatedresource.FromString(setID)
+	notification := notifier.Notification{
+		ReleaseNamespace: releaseKey.Namespace,
+		ReleaseName:      releaseKey.Name,
+		Time:             time.Now(),
+	}
+	sources := applied.Sources()
+	for _, reverted := range applied.Reverted() {
+		metadata, err := meta.Accessor(reverted)
+		if err != nil {
+			return nil, err
+		}
+		objGVK, err := gvk.Get(reverted)
+		if err != nil {
+			return nil, err
+		}
+		apiVersion, kind := objGVK.ToAPIVersionAndKind()
+		notification.Objects = append(notification.Objects, notifier.Object{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  metadata.GetNamespace(),
+			Name:       metadata.GetName(),
+			Source:     sources.For(reverted),
+		})
+	}
+	// sinks may be slow or retrying, so do not block other handlers
+	go h.notifier.Notify(h.ctx, notification)
+	return nil, nil
+}
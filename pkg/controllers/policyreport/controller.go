@@ -0,0 +1,374 @@
+package policyreport
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/lasso/pkg/controller"
+	"github.com/rancher/wrangler/v3/pkg/gvk"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// PolicyReportName is the name of the PolicyReport maintained in each namespace that contains locked objects, and of
+	// the ClusterPolicyReport that reports on locked cluster-scoped objects
+	PolicyReportName = "helm-locker"
+
+	// Policy is the name of the policy that results in the PolicyReport are reported against
+	Policy = "helm-locker"
+
+	// Rule is the name of the rule that results in the PolicyReport are reported against
+	Rule = "locked-to-helm-release"
+
+	// ReleaseProperty is the result property that identifies the namespace/name of the Helm release that locks an object
+	ReleaseProperty = "release"
+
+	// PassResult is the result reported for an object that matches its Helm release manifest
+	PassResult = "pass"
+
+	// FailResult is the result reported for an object that currently does not match its Helm release manifest, i.e. it
+	// is no longer reverted or did not match the manifest after it was last reverted
+	FailResult = "fail"
+)
+
+var (
+	// PolicyReportGVR is the GroupVersionResource of wg-policy PolicyReports
+	PolicyReportGVR = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "policyreports"}
+
+	// ClusterPolicyReportGVR is the GroupVersionResource of wg-policy ClusterPolicyReports
+	ClusterPolicyReportGVR = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "clusterpolicyreports"}
+
+	// managedBySelector selects the reports maintained by helm-locker
+	managedBySelector = "app.kubernetes.io/managed-by=" + Policy
+)
+
+type handler struct {
+	ctx        context.Context
+	dynamic    dynamic.Interface
+	restMapper meta.RESTMapper
+
+	// reportedBySetID keeps track of the namespaces whose PolicyReport contains results of an ObjectSet, where the
+	// ClusterPolicyReport is tracked as the empty namespace
+	reportedBySetID map[string]map[string]bool
+	// reportedLock is a lock on the reportedBySetID map
+	reportedLock sync.Mutex
+}
+
+// Register maintains a wg-policy PolicyReport in every namespace that contains an object locked by the
+// lockableObjectSetHandler, with one result per locked object
+// Results for cluster-scoped objects are reported in a ClusterPolicyReport
+func Register(ctx context.Context, dynamic dynamic.Interface, restMapper meta.RESTMapper, lockableObjectSetHandler *controller.SharedHandler) {
+	h := &handler{
+		ctx:             ctx,
+		dynamic:         dynamic,
+		restMapper:      restMapper,
+		reportedBySetID: make(map[string]map[string]bool),
+	}
+
+	lockableObjectSetHandler.Register(ctx, "report-on-objectset-change", controller.SharedControllerHandlerFunc(h.OnObjectSetChange))
+}
+
+// OnObjectSetChange replaces the results tied to the Helm release of the ObjectSet in the report of the namespace of
+// each of its objects
+func (h *handler) OnObjectSetChange(setID string, obj runtime.Object) (runtime.Object, error) {
+	releaseKey := relatedresource.FromString(setID)
+	resultsByNamespace := map[string][]interface{}{}
+	if applied, ok := obj.(objectset.Applied); ok {
+		var err error
+		resultsByNamespace, err = h.results(setID, releaseKey.Name, applied)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reported, err := h.getReported(setID)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := map[string]bool{}
+	for namespace := range reported {
+		namespaces[namespace] = true
+	}
+	for namespace := range resultsByNamespace {
+		namespaces[namespace] = true
+	}
+	for namespace := range namespaces {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return h.updateReport(namespace, setID, resultsByNamespace[namespace])
+		})
+		if meta.IsNoMatchError(err) {
+			logrus.Warnf("unable to report on release %s: %s", setID, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.setReported(setID, resultsByNamespace)
+	return nil, nil
+}
+
+// getReported returns the namespaces whose reports contain results of an ObjectSet
+// If the ObjectSet has not been reported on since helm-locker started, the existing reports are searched for its results
+func (h *handler) getReported(setID string) (map[string]bool, error) {
+	h.reportedLock.Lock()
+	reported, ok := h.reportedBySetID[setID]
+	h.reportedLock.Unlock()
+	if ok {
+		return reported, nil
+	}
+	reported = map[string]bool{}
+	for _, gvr := range []schema.GroupVersionResource{PolicyReportGVR, ClusterPolicyReportGVR} {
+		if _, err := h.restMapper.KindFor(gvr); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, err
+		}
+		reports, err := h.dynamic.Resource(gvr).List(h.ctx, metav1.ListOptions{LabelSelector: managedBySelector})
+		if err != nil {
+			return nil, err
+		}
+		for _, report := range reports.Items {
+			results, _, _ := unstructured.NestedSlice(report.Object, "results")
+			for _, result := range results {
+				if releaseOf(result) == setID {
+					reported[report.GetNamespace()] = true
+					break
+				}
+			}
+		}
+	}
+	return reported, nil
+}
+
+// setReported records the namespaces whose reports contain results of an ObjectSet
+func (h *handler) setReported(setID string, resultsByNamespace map[string][]interface{}) {
+	h.reportedLock.Lock()
+	defer h.reportedLock.Unlock()
+	if len(resultsByNamespace) == 0 {
+		// an ObjectSet without results is not reported on again until it has results, so there is nothing to search for
+		h.reportedBySetID[setID] = map[string]bool{}
+		return
+	}
+	reported := map[string]bool{}
+	for namespace := range resultsByNamespace {
+		reported[namespace] = true
+	}
+	h.reportedBySetID[setID] = reported
+}
+
+// results returns a PolicyReport result for each object tracked by an applied ObjectSet, by the namespace of the object
+func (h *handler) results(setID, releaseName string, applied objectset.Applied) (map[string][]interface{}, error) {
+	now := time.Now()
+	corrections := applied.Corrections()
+	results := map[string][]interface{}{}
+	for _, obj := range applied.GetObjectSet().All() {
+		metadata, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		objGVK, err := gvk.Get(obj)
+		if err != nil {
+			return nil, err
+		}
+		apiVersion, kind := objGVK.ToAPIVersionAndKind()
+		resource := map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"name":       metadata.GetName(),
+		}
+		if len(metadata.GetNamespace()) > 0 {
+			resource["namespace"] = metadata.GetNamespace()
+		}
+		result, message := resultOf(kind, setID, corrections, obj)
+		results[metadata.GetNamespace()] = append(results[metadata.GetNamespace()], map[string]interface{}{
+			"policy":    Policy,
+			"rule":      Rule,
+			"source":    Policy,
+			"result":    result,
+			"message":   message,
+			"scored":    true,
+			"resources": []interface{}{resource},
+			"properties": map[string]interface{}{
+				ReleaseProperty: setID,
+			},
+			"timestamp": map[string]interface{}{
+				"seconds": now.Unix(),
+				"nanos":   int64(now.Nanosecond()),
+			},
+		})
+	}
+	return results, nil
+}
+
+// resultOf returns the result and message reported for an object based on its current state
+//
+// An object that drifted only fails while it does not match the manifest, so it passes again once it has been reverted
+func resultOf(kind, setID string, corrections objectset.Corrections, obj runtime.Object) (string, string) {
+	correction, ok := corrections.For(obj)
+	if !ok {
+		return PassResult, fmt.Sprintf("%s matches the manifest of Helm release %s", kind, setID)
+	}
+	lastCorrected := correction.LastCorrected.UTC().Format(time.RFC3339)
+	switch {
+	case correction.Contested:
+		return FailResult, fmt.Sprintf("%s is contested and no longer reverted to the manifest of Helm release %s since it drifted %d time(s), last at %s", kind, setID, correction.Count, lastCorrected)
+	case correction.Unenforceable:
+		return FailResult, fmt.Sprintf("%s is unenforceable and no longer reverted to the manifest of Helm release %s since it did not match it at %s after being reverted", kind, setID, strings.Join(correction.DivergentPaths, ", "))
+	case len(correction.DivergentPaths) > 0:
+		return FailResult, fmt.Sprintf("%s does not match the manifest of Helm release %s at %s after being reverted at %s", kind, setID, strings.Join(correction.DivergentPaths, ", "), lastCorrected)
+	default:
+		return PassResult, fmt.Sprintf("%s matches the manifest of Helm release %s after drift was reverted %d time(s), last at %s", kind, setID, correction.Count, lastCorrected)
+	}
+}
+
+// updateReport replaces the results of a Helm release in the PolicyReport of a namespace, or the ClusterPolicyReport if
+// the namespace is empty
+// Results that have not changed keep the timestamp they were first reported at, and the report is not updated if none
+// of its results changed. If no results are left in the report, it is deleted.
+func (h *handler) updateReport(namespace, setID string, releaseResults []interface{}) error {
+	gvr, kind := PolicyReportGVR, "PolicyReport"
+	if len(namespace) == 0 {
+		gvr, kind = ClusterPolicyReportGVR, "ClusterPolicyReport"
+	}
+	if _, err := h.restMapper.KindFor(gvr); err != nil {
+		return err
+	}
+	var reports dynamic.ResourceInterface = h.dynamic.Resource(gvr)
+	if len(namespace) > 0 {
+		reports = h.dynamic.Resource(gvr).Namespace(namespace)
+	}
+
+	report, err := reports.Get(h.ctx, PolicyReportName, metav1.GetOptions{})
+	exists := err == nil
+	if apierrors.IsNotFound(err) {
+		report = &unstructured.Unstructured{}
+		report.SetGroupVersionKind(gvr.GroupVersion().WithKind(kind))
+		report.SetNamespace(namespace)
+		report.SetName(PolicyReportName)
+		report.SetLabels(map[string]string{
+			"app.kubernetes.io/managed-by": Policy,
+		})
+	} else if err != nil {
+		return err
+	}
+
+	existingResults, _, err := unstructured.NestedSlice(report.Object, "results")
+	if err != nil {
+		return err
+	}
+	var results []interface{}
+	previousResults := map[string]map[string]interface{}{}
+	for _, result := range existingResults {
+		if releaseOf(result) != setID {
+			results = append(results, result)
+			continue
+		}
+		if r, ok := result.(map[string]interface{}); ok {
+			previousResults[resourceOf(r)] = r
+		}
+	}
+	for _, result := range releaseResults {
+		r := result.(map[string]interface{})
+		if previous, ok := previousResults[resourceOf(r)]; ok && equalIgnoringTimestamp(previous, r) {
+			r["timestamp"] = previous["timestamp"]
+		}
+		results = append(results, r)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		releaseI, releaseJ := releaseOf(results[i]), releaseOf(results[j])
+		if releaseI != releaseJ {
+			return releaseI < releaseJ
+		}
+		return resourceOf(results[i].(map[string]interface{})) < resourceOf(results[j].(map[string]interface{}))
+	})
+
+	if len(results) == 0 {
+		if !exists {
+			return nil
+		}
+		err := reports.Delete(h.ctx, PolicyReportName, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if exists && reflect.DeepEqual(existingResults, results) {
+		return nil
+	}
+
+	summary := map[string]interface{}{
+		"pass":  int64(0),
+		"fail":  int64(0),
+		"warn":  int64(0),
+		"error": int64(0),
+		"skip":  int64(0),
+	}
+	for _, result := range results {
+		r, _, _ := unstructured.NestedString(result.(map[string]interface{}), "result")
+		if count, ok := summary[r].(int64); ok {
+			summary[r] = count + 1
+		}
+	}
+	report.Object["results"] = results
+	report.Object["summary"] = summary
+
+	if !exists {
+		_, err = reports.Create(h.ctx, report, metav1.CreateOptions{})
+		return err
+	}
+	_, err = reports.Update(h.ctx, report, metav1.UpdateOptions{})
+	return err
+}
+
+// releaseOf returns the namespace/name of the Helm release that a result was reported for
+func releaseOf(result interface{}) string {
+	r, ok := result.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	release, _, _ := unstructured.NestedString(r, "properties", ReleaseProperty)
+	return release
+}
+
+// resourceOf returns a key that identifies the object that a result was reported for
+func resourceOf(result map[string]interface{}) string {
+	resources, _, _ := unstructured.NestedSlice(result, "resources")
+	if len(resources) == 0 {
+		return ""
+	}
+	resource, ok := resources[0].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	var fields []string
+	for _, field := range []string{"apiVersion", "kind", "namespace", "name"} {
+		value, _, _ := unstructured.NestedString(resource, field)
+		fields = append(fields, value)
+	}
+	return strings.Join(fields, "/")
+}
+
+// equalIgnoringTimestamp returns whether two results are equal regardless of when they were reported
+func equalIgnoringTimestamp(a, b map[string]interface{}) bool {
+	a, b = maps.Clone(a), maps.Clone(b)
+	delete(a, "timestamp")
+	delete(b, "timestamp")
+	return reflect.DeepEqual(a, b)
+}
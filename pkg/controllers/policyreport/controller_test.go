@@ -0,0 +1,225 @@
+package policyreport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/helm-locker/pkg/objectset"
+	wranglerobjectset "github.com/rancher/wrangler/v3/pkg/objectset"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newTestHandler(clusterReports bool) (*handler, *dynamicfake.FakeDynamicClient) {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(PolicyReportGVR.GroupVersion().WithKind("PolicyReport"), meta.RESTScopeNamespace)
+	if clusterReports {
+		restMapper.Add(ClusterPolicyReportGVR.GroupVersion().WithKind("ClusterPolicyReport"), meta.RESTScopeRoot)
+	}
+	dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		PolicyReportGVR:        "PolicyReportList",
+		ClusterPolicyReportGVR: "ClusterPolicyReportList",
+	})
+	return &handler{
+		ctx:             context.Background(),
+		dynamic:         dynamic,
+		restMapper:      restMapper,
+		reportedBySetID: make(map[string]map[string]bool),
+	}, dynamic
+}
+
+func newTestResult(setID, namespace, name, result string, seconds int64) interface{} {
+	resource := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"name":       name,
+	}
+	if len(namespace) > 0 {
+		resource["namespace"] = namespace
+	}
+	return map[string]interface{}{
+		"policy":    Policy,
+		"rule":      Rule,
+		"source":    Policy,
+		"result":    result,
+		"message":   result,
+		"scored":    true,
+		"resources": []interface{}{resource},
+		"properties": map[string]interface{}{
+			ReleaseProperty: setID,
+		},
+		"timestamp": map[string]interface{}{
+			"seconds": seconds,
+			"nanos":   int64(0),
+		},
+	}
+}
+
+func countActions(dynamic *dynamicfake.FakeDynamicClient, verb string) int {
+	var count int
+	for _, action := range dynamic.Actions() {
+		if action.GetVerb() == verb {
+			count++
+		}
+	}
+	return count
+}
+
+func TestUpdateReportKeepsUnchangedResults(t *testing.T) {
+	h, dynamic := newTestHandler(true)
+
+	if err := h.updateReport("foo", "foo/foochart", []interface{}{
+		newTestResult("foo/foochart", "foo", "a", PassResult, 1),
+		newTestResult("foo/foochart", "foo", "b", PassResult, 1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updateReport("foo", "foo/foochart", []interface{}{
+		newTestResult("foo/foochart", "foo", "b", PassResult, 2),
+		newTestResult("foo/foochart", "foo", "a", PassResult, 2),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if updates := countActions(dynamic, "update"); updates != 0 {
+		t.Errorf("expected report with unchanged results to not be updated, got %d updates", updates)
+	}
+
+	if err := h.updateReport("foo", "foo/foochart", []interface{}{
+		newTestResult("foo/foochart", "foo", "a", PassResult, 3),
+		newTestResult("foo/foochart", "foo", "b", FailResult, 3),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := dynamic.Resource(PolicyReportGVR).Namespace("foo").Get(context.Background(), PolicyReportName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, _ := unstructured.NestedSlice(report.Object, "results")
+	var seconds []int64
+	for _, result := range results {
+		s, _, _ := unstructured.NestedInt64(result.(map[string]interface{}), "timestamp", "seconds")
+		seconds = append(seconds, s)
+	}
+	if len(seconds) != 2 || seconds[0] != 1 || seconds[1] != 3 {
+		t.Errorf("expected only the changed result to be timestamped again, got timestamps %v", seconds)
+	}
+}
+
+func TestOnObjectSetChangeReportsByNamespace(t *testing.T) {
+	h, dynamic := newTestHandler(true)
+
+	if err := h.updateReport("", "foo/foochart", []interface{}{newTestResult("foo/foochart", "", "a", PassResult, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updateReport("bar", "foo/foochart", []interface{}{newTestResult("foo/foochart", "bar", "a", PassResult, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dynamic.Resource(ClusterPolicyReportGVR).Get(context.Background(), PolicyReportName, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected cluster-scoped objects to be reported in a ClusterPolicyReport: %s", err)
+	}
+
+	// results of a release that is no longer locked are found in existing reports and removed
+	if _, err := h.OnObjectSetChange("foo/foochart", nil); err != nil {
+		t.Fatal(err)
+	}
+	if deletes := countActions(dynamic, "delete"); deletes != 2 {
+		t.Errorf("expected the reports that only contained results of the release to be deleted, got %d deletes", deletes)
+	}
+}
+
+func TestUpdateReportNotInstalled(t *testing.T) {
+	h, _ := newTestHandler(false)
+
+	err := h.updateReport("", "foo/foochart", []interface{}{newTestResult("foo/foochart", "", "a", PassResult, 1)})
+	if !meta.IsNoMatchError(err) {
+		t.Errorf("expected a missing ClusterPolicyReport CRD to be reported as a no match error, got %v", err)
+	}
+	if _, err := h.OnObjectSetChange("foo/foochart", nil); err != nil {
+		t.Errorf("expected a missing ClusterPolicyReport CRD to be skipped, got %s", err)
+	}
+}
+
+// testApplied is an objectset.Applied that only returns an ObjectSet and the corrections made to its objects
+type testApplied struct {
+	objectset.Applied
+
+	os          *wranglerobjectset.ObjectSet
+	corrections objectset.Corrections
+}
+
+func (a *testApplied) GetObjectSet() *wranglerobjectset.ObjectSet {
+	return a.os
+}
+
+func (a *testApplied) Corrections() objectset.Corrections {
+	return a.corrections
+}
+
+func TestResultsReflectCurrentState(t *testing.T) {
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetNamespace("foo")
+	configMap.SetName("a")
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	configMapKey := wranglerobjectset.ObjectKey{Namespace: "foo", Name: "a"}
+
+	testCases := []struct {
+		name       string
+		correction *objectset.Correction
+		expected   string
+	}{
+		{
+			name:     "never reverted",
+			expected: PassResult,
+		},
+		{
+			name:       "reverted",
+			correction: &objectset.Correction{Count: 3, LastCorrected: time.Now()},
+			expected:   PassResult,
+		},
+		{
+			name:       "not matching after being reverted",
+			correction: &objectset.Correction{Count: 1, LastCorrected: time.Now(), DivergentPaths: []string{"data.foo"}},
+			expected:   FailResult,
+		},
+		{
+			name:       "contested",
+			correction: &objectset.Correction{Count: 6, LastCorrected: time.Now(), Contested: true},
+			expected:   FailResult,
+		},
+		{
+			name:       "unenforceable",
+			correction: &objectset.Correction{Count: 3, LastCorrected: time.Now(), Unenforceable: true, DivergentPaths: []string{"data.foo"}},
+			expected:   FailResult,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, _ := newTestHandler(true)
+			applied := &testApplied{
+				os:          wranglerobjectset.NewObjectSet(configMap),
+				corrections: objectset.Corrections{},
+			}
+			if tc.correction != nil {
+				applied.corrections[configMapGVK] = map[wranglerobjectset.ObjectKey]objectset.Correction{configMapKey: *tc.correction}
+			}
+			results, err := h.results("foo/foochart", "foochart", applied)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results["foo"]) != 1 {
+				t.Fatalf("expected a result for the object, got %v", results)
+			}
+			if result := results["foo"][0].(map[string]interface{})["result"]; result != tc.expected {
+				t.Errorf("expected result %s, got %s", tc.expected, result)
+			}
+		})
+	}
+}
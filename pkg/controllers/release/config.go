@@ -0,0 +1,38 @@
+package release
+
+import (
+	"fmt"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+)
+
+// lockConfig returns the configuration used to lock the objects of a HelmRelease
+func (h *handler) lockConfig(helmRelease *v1alpha1.HelmRelease) (objectset.Config, error) {
+	flapDetection, err := h.flapDetection(helmRelease)
+	if err != nil {
+		return objectset.Config{}, err
+	}
+	config := objectset.Config{
+		FlapDetection: flapDetection,
+	}
+	if recreate := helmRelease.Spec.Recreate; recreate != nil {
+		config.Recreate = objectset.Recreate{
+			All:   recreate.All,
+			Kinds: recreate.Kinds,
+		}
+	}
+	if strayObjects := helmRelease.Spec.StrayObjects; strayObjects != nil {
+		switch strayObjects.Policy {
+		case "", v1alpha1.ReportStrayObjectPolicy, v1alpha1.PruneStrayObjectPolicy:
+		default:
+			return objectset.Config{}, fmt.Errorf("invalid stray object policy %q: must be one of %s or %s", strayObjects.Policy, v1alpha1.ReportStrayObjectPolicy, v1alpha1.PruneStrayObjectPolicy)
+		}
+		config.StrayObjects = objectset.StrayObjects{
+			Enabled: true,
+			Prune:   strayObjects.Policy == v1alpha1.PruneStrayObjectPolicy,
+			DryRun:  strayObjects.DryRun,
+		}
+	}
+	return config, nil
+}
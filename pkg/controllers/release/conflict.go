@@ -0,0 +1,60 @@
+package release
+
+import (
+	"fmt"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// onConflicts emits events for the conflicts found on locking a release and updates the Conflicted condition
+// of every other release that no longer locks objects as a result
+func (h *handler) onConflicts(releaseKey relatedresource.Key, conflicts []objectset.Conflict) error {
+	contenders := map[relatedresource.Key]bool{}
+	for _, conflict := range conflicts {
+		helmReleases, err := h.helmReleaseCache.GetByIndex(HelmReleaseByReleaseKey, releaseKeyToString(conflict.Contender))
+		if err != nil {
+			return err
+		}
+		for _, helmRelease := range helmReleases {
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "Conflicted", "Unable to lock %s %s since it is locked by release %s", conflict.GVK.Kind, conflict.Key, releaseKeyToString(conflict.Owner))
+		}
+		if conflict.Contender != releaseKey {
+			contenders[conflict.Contender] = true
+		}
+	}
+	for contender := range contenders {
+		helmReleases, err := h.helmReleaseCache.GetByIndex(HelmReleaseByReleaseKey, releaseKeyToString(contender))
+		if err != nil {
+			return err
+		}
+		contenderConflicts := h.lockableObjectSetRegister.Conflicts(contender)
+		for _, helmRelease := range helmReleases {
+			if err := h.updateStatus(helmRelease, func(status *v1alpha1.HelmReleaseStatus) {
+				setConflicted(status, contenderConflicts)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setConflicted sets the Conflicted condition of a HelmRelease based on the objects it cannot lock
+func setConflicted(status *v1alpha1.HelmReleaseStatus, conflicts []objectset.Conflict) {
+	conflicted := condition.Cond(v1alpha1.ConflictedCondition)
+	if len(conflicts) == 0 {
+		conflicted.False(status)
+		conflicted.Reason(status, "")
+		conflicted.Message(status, "")
+		return
+	}
+	conflicted.True(status)
+	conflicted.Reason(status, "LockedByAnotherRelease")
+	conflicted.Message(status, fmt.Sprintf("unable to lock %d objects: %s", len(conflicts), describeFirst(len(conflicts), func(i int) string {
+		return conflicts[i].String()
+	})))
+}
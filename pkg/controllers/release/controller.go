@@ -3,9 +3,12 @@ package release
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
 	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
 	helmcontroller "github.com/rancher/helm-locker/pkg/generated/controllers/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/health"
 	"github.com/rancher/helm-locker/pkg/objectset"
 	"github.com/rancher/helm-locker/pkg/objectset/parser"
 	"github.com/rancher/helm-locker/pkg/releases"
@@ -17,6 +20,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/storage/driver"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
@@ -38,11 +42,22 @@ type handler struct {
 	helmReleaseCache helmcontroller.HelmReleaseCache
 	secrets          corecontroller.SecretController
 	secretCache      corecontroller.SecretCache
+	configMaps       corecontroller.ConfigMapController
 
-	releases releases.HelmReleaseGetter
+	releases           releases.HelmReleaseGetter
+	restMapper         meta.RESTMapper
+	apiVersionMappings parser.APIVersionMappings
 
 	lockableObjectSetRegister objectset.LockableRegister
+	defaultFlapDetection      objectset.FlapDetection
+	purgeOptions              PurgeOptions
+	healthChecker             health.Checker
 	recorder                  record.EventRecorder
+
+	// pendingDriftByHelmRelease are the drift records of each HelmRelease that have not been recorded in its status yet
+	pendingDriftByHelmRelease map[string][]v1alpha1.DriftRecord
+	// pendingDriftLock is a lock on the pendingDriftByHelmRelease map
+	pendingDriftLock sync.Mutex
 }
 
 func Register(
@@ -52,9 +67,15 @@ func Register(
 	helmReleaseCache helmcontroller.HelmReleaseCache,
 	secrets corecontroller.SecretController,
 	secretCache corecontroller.SecretCache,
+	configMaps corecontroller.ConfigMapController,
 	k8s kubernetes.Interface,
+	restMapper meta.RESTMapper,
+	apiVersionMappings parser.APIVersionMappings,
 	lockableObjectSetRegister objectset.LockableRegister,
 	lockableObjectSetHandler *controller.SharedHandler,
+	defaultFlapDetection objectset.FlapDetection,
+	purgeOptions PurgeOptions,
+	healthChecker health.Checker,
 	recorder record.EventRecorder,
 ) {
 
@@ -66,11 +87,19 @@ func Register(
 		helmReleaseCache: helmReleaseCache,
 		secrets:          secrets,
 		secretCache:      secretCache,
+		configMaps:       configMaps,
 
-		releases: releases.NewHelmReleaseGetter(k8s),
+		releases:           releases.NewHelmReleaseGetter(k8s),
+		restMapper:         restMapper,
+		apiVersionMappings: apiVersionMappings,
 
 		lockableObjectSetRegister: lockableObjectSetRegister,
+		defaultFlapDetection:      defaultFlapDetection,
+		purgeOptions:              purgeOptions,
+		healthChecker:             healthChecker,
 		recorder:                  recorder,
+
+		pendingDriftByHelmRelease: make(map[string][]v1alpha1.DriftRecord),
 	}
 
 	lockableObjectSetHandler.Register(ctx, "on-objectset-change", controller.SharedControllerHandlerFunc(h.OnObjectSetChange))
@@ -119,6 +148,7 @@ func (h *handler) OnObjectSetChange(setID string, obj runtime.Object) (runtime.O
 	if err != nil {
 		return nil, fmt.Errorf("unable to find HelmReleases for objectset %s to trigger event", setID)
 	}
+	releaseKey := relatedresource.FromString(setID)
 	for _, helmRelease := range helmReleases {
 		if helmRelease == nil {
 			continue
@@ -128,6 +158,100 @@ func (h *handler) OnObjectSetChange(setID string, obj runtime.Object) (runtime.O
 		} else {
 			h.recorder.Eventf(helmRelease, corev1.EventTypeNormal, "Untracked", "ObjectSet %s tied to HelmRelease %s/%s is not tracked", setID, helmRelease.Namespace, helmRelease.Name)
 		}
+		applied, ok := obj.(objectset.Applied)
+		if !ok {
+			inventory, err := h.storeInventory(helmRelease, nil)
+			if err != nil {
+				return nil, fmt.Errorf("unable to store inventory of HelmRelease %s: %s", helmRelease.GetName(), err)
+			}
+			if err := h.updateStatus(helmRelease, func(status *v1alpha1.HelmReleaseStatus) {
+				clearHealth(status)
+				setInventory(status, inventory)
+				setConflicted(status, nil)
+				setStrayObjects(status, nil)
+			}); err != nil {
+				return nil, fmt.Errorf("unable to update status of HelmRelease %s: %s", helmRelease.GetName(), err)
+			}
+			continue
+		}
+		sources := applied.Sources()
+		drifts, err := driftRecords(applied.Drifts(), sources)
+		if err != nil {
+			return nil, fmt.Errorf("unable to record drift corrections of HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+		// drift is only reported on this apply, so it is kept until it is recorded in the status of the HelmRelease
+		drifts = h.addPendingDrift(helmRelease, drifts)
+		for _, reverted := range applied.Reverted() {
+			// emitted on the object itself so that it is visible to users in the object's namespace
+			h.recorder.Eventf(reverted, corev1.EventTypeWarning, "Reverted", "Reverted changes to match the Helm release locked by HelmRelease %s/%s%s", helmRelease.Namespace, helmRelease.Name, renderedBy(sources.For(reverted)))
+		}
+		for _, contested := range applied.Contested() {
+			ref, err := objectReference(contested)
+			if err != nil {
+				return nil, fmt.Errorf("unable to identify contested object of HelmRelease %s: %s", helmRelease.GetName(), err)
+			}
+			h.recorder.Eventf(contested, corev1.EventTypeWarning, "Contested", "Stopped reverting changes since another actor keeps modifying this object locked by HelmRelease %s/%s%s", helmRelease.Namespace, helmRelease.Name, renderedBy(sources.For(contested)))
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "Contested", "Stopped reverting changes to %s %s%s since another actor keeps modifying it", ref.Kind, objectKeyString(ref), renderedBy(sources.For(contested)))
+		}
+		corrections := applied.Corrections()
+		for _, unenforceable := range applied.Unenforceable() {
+			ref, err := objectReference(unenforceable)
+			if err != nil {
+				return nil, fmt.Errorf("unable to identify unenforceable object of HelmRelease %s: %s", helmRelease.GetName(), err)
+			}
+			correction, _ := corrections.For(unenforceable)
+			paths := strings.Join(correction.DivergentPaths, ", ")
+			h.recorder.Eventf(unenforceable, corev1.EventTypeWarning, "Unenforceable", "Stopped reverting changes since fields [%s] never match the Helm release locked by HelmRelease %s/%s%s", paths, helmRelease.Namespace, helmRelease.Name, renderedBy(sources.For(unenforceable)))
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "Unenforceable", "Stopped reverting changes to %s %s%s since fields [%s] never match the Helm release", ref.Kind, objectKeyString(ref), renderedBy(sources.For(unenforceable)), paths)
+		}
+		for _, recreated := range applied.Recreated() {
+			ref, err := objectReference(recreated)
+			if err != nil {
+				return nil, fmt.Errorf("unable to identify re-created object of HelmRelease %s: %s", helmRelease.GetName(), err)
+			}
+			h.recorder.Eventf(recreated, corev1.EventTypeWarning, "Recreated", "Deleted and re-created since it could not be updated to match the Helm release locked by HelmRelease %s/%s%s", helmRelease.Namespace, helmRelease.Name, renderedBy(sources.For(recreated)))
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "Recreated", "Deleted and re-created %s %s%s since it could not be updated to match the Helm release", ref.Kind, objectKeyString(ref), renderedBy(sources.For(recreated)))
+		}
+		objs := applied.GetObjectSet().All()
+		healthResults, err := h.healthChecker.Check(objs)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check health of objects locked by HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+		unhealthy, err := unhealthyObjects(healthResults)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check health of objects locked by HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+		entries, err := inventoryEntries(applied)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute inventory of HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+		inventory, err := h.storeInventory(helmRelease, entries)
+		if err != nil {
+			return nil, fmt.Errorf("unable to store inventory of HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+		conflicts := h.lockableObjectSetRegister.Conflicts(releaseKey)
+		if err := h.updateStatus(helmRelease, func(status *v1alpha1.HelmReleaseStatus) {
+			setHealth(status, unhealthy, len(objs))
+			setInventory(status, inventory)
+			recordDrift(status, drifts)
+			setConflicted(status, conflicts)
+			setStrayObjects(status, applied.Strays())
+		}); err != nil {
+			if len(drifts) > 0 {
+				// errors returned by this handler are not retried, so the HelmRelease records the pending drift instead
+				h.helmReleases.Enqueue(helmRelease.Namespace, helmRelease.Name)
+			}
+			return nil, fmt.Errorf("unable to update status of HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+		h.removePendingDrift(helmRelease, drifts)
+		if err := h.onStrays(helmRelease, applied.Strays()); err != nil {
+			return nil, fmt.Errorf("unable to report stray objects of HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
+	}
+	if applied, ok := obj.(objectset.Applied); ok {
+		if err := h.onConflicts(releaseKey, applied.Conflicts()); err != nil {
+			return nil, fmt.Errorf("unable to report conflicts of objectset %s: %s", setID, err)
+		}
 	}
 	return nil, nil
 }
@@ -205,7 +329,8 @@ func (h *handler) OnHelmReleaseRemove(_ string, helmRelease *v1alpha1.HelmReleas
 	logrus.Warnf("HelmRelease %s/%s was removed, resources tied to Helm release may need to be manually deleted", helmRelease.Namespace, helmRelease.Name)
 	logrus.Warnf("To delete the contents of a Helm release automatically, delete the Helm release secret before deleting the HelmRelease.")
 	releaseKey := releaseKeyFromRelease(helmRelease)
-	h.lockableObjectSetRegister.Delete(releaseKey, false) // remove the objectset, but don't purge the underlying resources
+	h.lockableObjectSetRegister.Delete(releaseKey) // remove the objectset, but don't purge the underlying resources
+	h.clearPendingDrift(helmRelease)
 	return helmRelease, nil
 }
 
@@ -222,36 +347,59 @@ func (h *handler) OnHelmRelease(_ string, helmRelease *v1alpha1.HelmRelease) (*v
 	latestRelease, err := h.releases.Last(releaseKey.Namespace, releaseKey.Name)
 	if err != nil {
 		if err == driver.ErrReleaseNotFound {
-			logrus.Warnf("waiting for release %s/%s to be found to reconcile HelmRelease %s, deleting any orphaned resources", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName())
-			h.lockableObjectSetRegister.Delete(releaseKey, true) // remove the objectset and purge any untracked resources
-			helmRelease.Status.Version = 0
-			helmRelease.Status.Description = "Could not find Helm Release Secret"
-			helmRelease.Status.State = v1alpha1.SecretNotFoundState
-			helmRelease.Status.Notes = ""
-			return h.helmReleases.UpdateStatus(helmRelease)
+			return h.onReleaseNotFound(helmRelease, releaseKey)
 		}
 		return helmRelease, fmt.Errorf("unable to find latest Helm Release Secret tied to Helm Release %s: %s", helmRelease.GetName(), err)
 	}
 	logrus.Infof("loading latest release version %d of HelmRelease %s", latestRelease.Version, helmRelease.GetName())
 	releaseInfo := newReleaseInfo(latestRelease)
-	helmRelease, err = h.helmReleases.UpdateStatus(releaseInfo.GetUpdatedStatus(helmRelease))
+	helmRelease = releaseInfo.GetUpdatedStatus(helmRelease)
+	clearPurgePending(&helmRelease.Status)
+	helmRelease, err = h.helmReleases.UpdateStatus(helmRelease)
 	if err != nil {
 		return helmRelease, fmt.Errorf("unable to update status of HelmRelease %s: %s", helmRelease.GetName(), err)
 	}
+	if err := h.recordPendingDrift(helmRelease); err != nil {
+		return helmRelease, err
+	}
 	if !releaseInfo.Locked() {
 		// TODO: add status
 		logrus.Infof("detected HelmRelease %s is not deployed or transitioning (state is %s), unlocking release", helmRelease.GetName(), releaseInfo.State)
 		h.lockableObjectSetRegister.Unlock(releaseKey)
+		if err := h.unlockInventory(helmRelease); err != nil {
+			return helmRelease, fmt.Errorf("unable to update inventory of HelmRelease %s: %s", helmRelease.GetName(), err)
+		}
 		h.recorder.Eventf(helmRelease, corev1.EventTypeNormal, "Transitioning", "Unlocked HelmRelease %s/%s to allow changes while Helm operation is being executed", helmRelease.Namespace, helmRelease.Name)
 		return helmRelease, nil
 	}
-	manifestOS, err := parser.Parse(releaseInfo.Manifest)
+	config, err := h.lockConfig(helmRelease)
 	if err != nil {
-		// TODO: add status
-		return helmRelease, fmt.Errorf("unable to parse objectset from manifest for HelmRelease %s: %s", helmRelease.GetName(), err)
+		return helmRelease, fmt.Errorf("unable to configure locking for HelmRelease %s: %s", helmRelease.GetName(), err)
+	}
+	manifestOS, releasedOS, sources, err := h.parseRelease(helmRelease, releaseKey, releaseInfo)
+	unserved, parseErr := splitUnservedErrors(err)
+	if parseErr != nil {
+		// objects of documents that could not be parsed may have been locked before, so they must not be pruned
+		logrus.Errorf("unable to parse all objects from manifest for HelmRelease %s, only locking objects that were parsed: %s", helmRelease.GetName(), parseErr)
+		config.Incomplete = true
+	}
+	h.lockableObjectSetRegister.Configure(releaseKey, config)
+	if err := h.updateStatus(helmRelease, func(status *v1alpha1.HelmReleaseStatus) {
+		setManifestParsed(status, parseErr)
+		setAPIVersionsServed(status, unserved)
+	}); err != nil {
+		return helmRelease, fmt.Errorf("unable to update status of HelmRelease %s: %s", helmRelease.GetName(), err)
+	}
+	if parseErr != nil {
+		h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "InvalidManifest", "Not locking objects of HelmRelease %s/%s whose documents could not be parsed: %s", helmRelease.Namespace, helmRelease.Name, describeErrors(flattenErrors(parseErr)))
+	}
+	if len(unserved) > 0 {
+		h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "UnservedAPIVersion", "Not locking %d object(s) of HelmRelease %s/%s whose apiVersion is not served by the cluster: %s", len(unserved), helmRelease.Namespace, helmRelease.Name, describeErrors(unserved))
 	}
 	logrus.Infof("detected HelmRelease %s is deployed, locking release %s with %d objects", helmRelease.GetName(), releaseKey, len(manifestOS.All()))
 	locked := true
+	h.lockableObjectSetRegister.SetSources(releaseKey, sources)
+	h.lockableObjectSetRegister.SetReleased(releaseKey, releasedOS)
 	h.lockableObjectSetRegister.Set(releaseKey, manifestOS, &locked)
 	return helmRelease, nil
 }
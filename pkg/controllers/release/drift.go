@@ -0,0 +1,115 @@
+package release
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+)
+
+const (
+	// maxDriftHistory is the maximum number of drift corrections kept in the status of a HelmRelease
+	maxDriftHistory = 10
+)
+
+// driftRecords returns a DriftRecord for each drift that was reverted
+func driftRecords(drifts []objectset.Drift, sources objectset.Sources) ([]v1alpha1.DriftRecord, error) {
+	records := make([]v1alpha1.DriftRecord, 0, len(drifts))
+	for _, drift := range drifts {
+		ref, err := objectReference(drift.Object)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, v1alpha1.DriftRecord{
+			ObjectReference: ref,
+			Time:            drift.Time.UTC().Format(time.RFC3339),
+			Actor:           drift.Actor,
+			DiffDigest:      drift.Digest,
+			Source:          sources.For(drift.Object),
+		})
+	}
+	return records, nil
+}
+
+// recordDrift adds drift corrections to the counters and history of a HelmRelease, evicting the oldest records
+// once the history is full
+func recordDrift(status *v1alpha1.HelmReleaseStatus, records []v1alpha1.DriftRecord) {
+	if len(records) == 0 {
+		return
+	}
+	status.DriftCorrections += int64(len(records))
+	status.LastDriftCorrection = records[len(records)-1].Time
+
+	history := append(append([]v1alpha1.DriftRecord{}, status.DriftHistory...), records...)
+	if len(history) > maxDriftHistory {
+		history = history[len(history)-maxDriftHistory:]
+	}
+	status.DriftHistory = history
+}
+
+// addPendingDrift adds drift records to those that have not been recorded in the status of a HelmRelease yet and returns
+// every pending record
+//
+// Drift is only reported once when it is reverted, so records are kept until the status of the HelmRelease is updated
+func (h *handler) addPendingDrift(helmRelease *v1alpha1.HelmRelease, records []v1alpha1.DriftRecord) []v1alpha1.DriftRecord {
+	h.pendingDriftLock.Lock()
+	defer h.pendingDriftLock.Unlock()
+	key := pendingDriftKey(helmRelease)
+	pending := h.pendingDriftByHelmRelease[key]
+	for _, record := range records {
+		if !slices.Contains(pending, record) {
+			pending = append(pending, record)
+		}
+	}
+	if len(pending) > 0 {
+		h.pendingDriftByHelmRelease[key] = pending
+	}
+	return append([]v1alpha1.DriftRecord{}, pending...)
+}
+
+// removePendingDrift removes drift records that were recorded in the status of a HelmRelease from those that are pending
+func (h *handler) removePendingDrift(helmRelease *v1alpha1.HelmRelease, records []v1alpha1.DriftRecord) {
+	h.pendingDriftLock.Lock()
+	defer h.pendingDriftLock.Unlock()
+	key := pendingDriftKey(helmRelease)
+	var pending []v1alpha1.DriftRecord
+	for _, record := range h.pendingDriftByHelmRelease[key] {
+		if !slices.Contains(records, record) {
+			pending = append(pending, record)
+		}
+	}
+	if len(pending) == 0 {
+		delete(h.pendingDriftByHelmRelease, key)
+		return
+	}
+	h.pendingDriftByHelmRelease[key] = pending
+}
+
+// clearPendingDrift drops the drift records of a HelmRelease that have not been recorded in its status
+func (h *handler) clearPendingDrift(helmRelease *v1alpha1.HelmRelease) {
+	h.pendingDriftLock.Lock()
+	defer h.pendingDriftLock.Unlock()
+	delete(h.pendingDriftByHelmRelease, pendingDriftKey(helmRelease))
+}
+
+// recordPendingDrift records the drift records of a HelmRelease that could not be recorded in its status before
+func (h *handler) recordPendingDrift(helmRelease *v1alpha1.HelmRelease) error {
+	pending := h.addPendingDrift(helmRelease, nil)
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := h.updateStatus(helmRelease, func(status *v1alpha1.HelmReleaseStatus) {
+		recordDrift(status, pending)
+	}); err != nil {
+		return fmt.Errorf("unable to record %d pending drift correction(s) of HelmRelease %s: %s", len(pending), helmRelease.GetName(), err)
+	}
+	h.removePendingDrift(helmRelease, pending)
+	return nil
+}
+
+// pendingDriftKey returns the key of a HelmRelease in the pendingDriftByHelmRelease map
+func pendingDriftKey(helmRelease *v1alpha1.HelmRelease) string {
+	return fmt.Sprintf("%s/%s", helmRelease.Namespace, helmRelease.Name)
+}
@@ -0,0 +1,32 @@
+package release
+
+import (
+	"testing"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPendingDrift(t *testing.T) {
+	h := &handler{pendingDriftByHelmRelease: make(map[string][]v1alpha1.DriftRecord)}
+	helmRelease := &v1alpha1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-helm-system", Name: "foochart"}}
+	first := v1alpha1.DriftRecord{ObjectReference: v1alpha1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "foo", Name: "a"}, Time: "2024-01-01T00:00:00Z"}
+	second := v1alpha1.DriftRecord{ObjectReference: v1alpha1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "foo", Name: "b"}, Time: "2024-01-01T00:01:00Z"}
+
+	// records that failed to be persisted are kept and returned along with new ones, without duplicates
+	h.addPendingDrift(helmRelease, []v1alpha1.DriftRecord{first})
+	pending := h.addPendingDrift(helmRelease, []v1alpha1.DriftRecord{first, second})
+	if len(pending) != 2 || pending[0] != first || pending[1] != second {
+		t.Fatalf("expected both records to be pending, got %v", pending)
+	}
+
+	// only the records that were persisted are removed
+	h.removePendingDrift(helmRelease, []v1alpha1.DriftRecord{first})
+	if pending := h.addPendingDrift(helmRelease, nil); len(pending) != 1 || pending[0] != second {
+		t.Errorf("expected only the second record to be pending, got %v", pending)
+	}
+	h.removePendingDrift(helmRelease, []v1alpha1.DriftRecord{second})
+	if len(h.pendingDriftByHelmRelease) != 0 {
+		t.Errorf("expected no records to be pending, got %v", h.pendingDriftByHelmRelease)
+	}
+}
@@ -0,0 +1,32 @@
+package release
+
+import (
+	"fmt"
+	"time"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+)
+
+// flapDetection returns the FlapDetection configured by a HelmRelease, if it overrides the default FlapDetection
+func (h *handler) flapDetection(helmRelease *v1alpha1.HelmRelease) (*objectset.FlapDetection, error) {
+	spec := helmRelease.Spec.FlapDetection
+	if spec == nil {
+		return nil, nil
+	}
+	flapDetection := h.defaultFlapDetection
+	if spec.Threshold != nil {
+		flapDetection.Threshold = *spec.Threshold
+	}
+	if len(spec.Window) > 0 {
+		window, err := time.ParseDuration(spec.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flap detection window %q: %s", spec.Window, err)
+		}
+		flapDetection.Window = window
+	}
+	if err := flapDetection.Validate(); err != nil {
+		return nil, err
+	}
+	return &flapDetection, nil
+}
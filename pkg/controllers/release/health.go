@@ -0,0 +1,66 @@
+package release
+
+import (
+	"fmt"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/health"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+)
+
+// unhealthyObjects returns the health of each object whose health is not Current
+func unhealthyObjects(results []health.Result) ([]v1alpha1.ObjectHealth, error) {
+	var unhealthy []v1alpha1.ObjectHealth
+	for _, result := range results {
+		if result.Status == health.CurrentStatus {
+			continue
+		}
+		ref, err := objectReference(result.Object)
+		if err != nil {
+			return nil, err
+		}
+		unhealthy = append(unhealthy, v1alpha1.ObjectHealth{
+			ObjectReference: ref,
+			Status:          string(result.Status),
+			Message:         result.Message,
+		})
+	}
+	return unhealthy, nil
+}
+
+// setHealth sets the Healthy condition and the unhealthy objects of a HelmRelease that locks numObjects objects
+func setHealth(status *v1alpha1.HelmReleaseStatus, unhealthy []v1alpha1.ObjectHealth, numObjects int) {
+	status.UnhealthyObjects = unhealthy
+
+	failed := 0
+	for _, objectHealth := range unhealthy {
+		if objectHealth.Status == string(health.FailedStatus) {
+			failed++
+		}
+	}
+
+	healthy := condition.Cond(v1alpha1.HealthyCondition)
+	switch {
+	case failed > 0:
+		healthy.False(status)
+		healthy.Reason(status, string(health.FailedStatus))
+		healthy.Message(status, fmt.Sprintf("%d of %d locked objects have failed", failed, numObjects))
+	case len(unhealthy) > 0:
+		healthy.Unknown(status)
+		healthy.Reason(status, string(health.InProgressStatus))
+		healthy.Message(status, fmt.Sprintf("%d of %d locked objects are in progress", len(unhealthy), numObjects))
+	default:
+		healthy.True(status)
+		healthy.Reason(status, string(health.CurrentStatus))
+		healthy.Message(status, fmt.Sprintf("all %d locked objects are current", numObjects))
+	}
+}
+
+// clearHealth removes the health of locked objects from a HelmRelease that no longer locks any objects
+func clearHealth(status *v1alpha1.HelmReleaseStatus) {
+	status.UnhealthyObjects = nil
+	healthy := condition.Cond(v1alpha1.HealthyCondition)
+	healthy.Unknown(status)
+	healthy.Reason(status, "NotLocked")
+	healthy.Message(status, "no objects are locked")
+}
@@ -1,7 +1,12 @@
 package release
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"helm.sh/helm/v3/pkg/chart"
 	rspb "helm.sh/helm/v3/pkg/release"
 )
 
@@ -9,6 +14,8 @@ func newReleaseInfo(release *rspb.Release) *releaseInfo {
 	info := &releaseInfo{}
 	info.Version = int(release.Version)
 	info.Manifest = release.Manifest
+	info.HookManifest = persistentHookManifest(release.Hooks)
+	info.CRDManifest = crdManifest(release.Chart)
 	if release.Info != nil {
 		info.Description = release.Info.Description
 		info.Notes = release.Info.Notes
@@ -33,11 +40,49 @@ func newReleaseInfo(release *rspb.Release) *releaseInfo {
 }
 
 type releaseInfo struct {
-	Version     int
-	Manifest    string
-	Description string
-	Notes       string
-	State       string
+	Version      int
+	Manifest     string
+	HookManifest string
+	CRDManifest  string
+	Description  string
+	Notes        string
+	State        string
+}
+
+// persistentHookManifest returns a manifest containing the resources created by hooks that are kept in the cluster
+//
+// Test hooks, hooks that have not succeeded, and hooks whose resources are deleted once they succeed or fail are excluded
+func persistentHookManifest(hooks []*rspb.Hook) string {
+	var manifest strings.Builder
+	for _, hook := range hooks {
+		if hook == nil || hook.LastRun.Phase != rspb.HookPhaseSucceeded {
+			continue
+		}
+		if slices.Contains(hook.Events, rspb.HookTest) {
+			continue
+		}
+		if slices.Contains(hook.DeletePolicies, rspb.HookSucceeded) || slices.Contains(hook.DeletePolicies, rspb.HookFailed) {
+			continue
+		}
+		fmt.Fprintf(&manifest, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+	}
+	return manifest.String()
+}
+
+// crdManifest returns a manifest containing the CustomResourceDefinitions in the crds/ directory of a chart, which Helm
+// installs but does not track in the manifest of a release
+func crdManifest(chart *chart.Chart) string {
+	if chart == nil {
+		return ""
+	}
+	var manifest strings.Builder
+	for _, crd := range chart.CRDObjects() {
+		if crd.File == nil {
+			continue
+		}
+		fmt.Fprintf(&manifest, "---\n# Source: %s\n%s\n", crd.Filename, crd.File.Data)
+	}
+	return manifest.String()
 }
 
 func (i *releaseInfo) Locked() bool {
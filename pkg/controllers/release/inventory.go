@@ -0,0 +1,341 @@
+package release
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// InventoryConfigMapKey is the key of the ConfigMap that contains the inventory of a HelmRelease that does not fit in its status
+	InventoryConfigMapKey = "inventory.json"
+
+	// InventoryOf is a label attached to ConfigMaps that contain the inventory of the HelmRelease with this name
+	InventoryOf = "helmreleases.cattle.io/inventory-of"
+
+	// maxInventorySize is the maximum size in bytes of an inventory that is stored uncompressed in the status of a HelmRelease
+	maxInventorySize = 16 * 1024
+
+	// maxCompressedInventorySize is the maximum size in bytes of an inventory that is stored compressed in the status of a HelmRelease
+	maxCompressedInventorySize = 64 * 1024
+)
+
+// inventoryEntries returns an entry for each object tracked by an applied ObjectSet
+func inventoryEntries(applied objectset.Applied) ([]v1alpha1.InventoryEntry, error) {
+	corrections := applied.Corrections()
+	sources := applied.Sources()
+	objs := applied.GetObjectSet().All()
+	entries := make([]v1alpha1.InventoryEntry, 0, len(objs))
+	for _, obj := range objs {
+		ref, err := objectReference(obj)
+		if err != nil {
+			return nil, err
+		}
+		entry := v1alpha1.InventoryEntry{
+			ObjectReference: ref,
+			Source:          sources.For(obj),
+			State:           v1alpha1.LockedObjectState,
+		}
+		if correction, ok := corrections.For(obj); ok {
+			entry.Corrections = correction.Count
+			entry.LastCorrected = correction.LastCorrected.UTC().Format(time.RFC3339)
+			entry.DivergentPaths = correction.DivergentPaths
+			switch {
+			case correction.Unenforceable:
+				entry.State = v1alpha1.UnenforceableObjectState
+			case correction.Contested:
+				entry.State = v1alpha1.ContestedObjectState
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// inventoryConfigMapName returns the name of the ConfigMap that contains the inventory of a HelmRelease that does not fit in its status
+func inventoryConfigMapName(helmRelease *v1alpha1.HelmRelease) string {
+	return fmt.Sprintf("%s-inventory", helmRelease.Name)
+}
+
+// storeInventory returns the inventory to set on the status of a HelmRelease
+//
+// Entries are stored uncompressed in the status if they are small enough, compressed in the status if they are still
+// too large, and in a ConfigMap owned by the HelmRelease otherwise.
+func (h *handler) storeInventory(helmRelease *v1alpha1.HelmRelease, entries []v1alpha1.InventoryEntry) (v1alpha1.Inventory, error) {
+	inventory := v1alpha1.Inventory{
+		Count: len(entries),
+		Kinds: trackedKinds(helmRelease.Status.Inventory.Kinds, entries),
+	}
+	if len(entries) == 0 {
+		return inventory, h.deleteInventoryConfigMap(helmRelease)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return inventory, err
+	}
+	if len(data) <= maxInventorySize {
+		inventory.Objects = entries
+		return inventory, h.deleteInventoryConfigMap(helmRelease)
+	}
+	compressed, err := compress(data)
+	if err != nil {
+		return inventory, err
+	}
+	if len(compressed) <= maxCompressedInventorySize {
+		inventory.Compressed = compressed
+		return inventory, h.deleteInventoryConfigMap(helmRelease)
+	}
+	inventory.ConfigMap = inventoryConfigMapName(helmRelease)
+	return inventory, h.saveInventoryConfigMap(helmRelease, data)
+}
+
+// loadInventory returns the entries of the inventory in the status of a HelmRelease, wherever they are stored
+func (h *handler) loadInventory(helmRelease *v1alpha1.HelmRelease) ([]v1alpha1.InventoryEntry, error) {
+	inventory := helmRelease.Status.Inventory
+	var data []byte
+	switch {
+	case len(inventory.Objects) > 0:
+		return inventory.Objects, nil
+	case len(inventory.Compressed) > 0:
+		decompressed, err := decompress(inventory.Compressed)
+		if err != nil {
+			return nil, err
+		}
+		data = decompressed
+	case len(inventory.ConfigMap) > 0:
+		configMap, err := h.configMaps.Get(helmRelease.Namespace, inventory.ConfigMap, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if !isInventoryOf(configMap, helmRelease) {
+			return nil, fmt.Errorf("ConfigMap %s/%s is not the inventory of HelmRelease %s", configMap.Namespace, configMap.Name, helmRelease.Name)
+		}
+		data = []byte(configMap.Data[InventoryConfigMapKey])
+	default:
+		return nil, nil
+	}
+	var entries []v1alpha1.InventoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// saveInventoryConfigMap creates or updates the ConfigMap that contains the inventory of a HelmRelease
+func (h *handler) saveInventoryConfigMap(helmRelease *v1alpha1.HelmRelease, data []byte) error {
+	name := inventoryConfigMapName(helmRelease)
+	configMap, err := h.configMaps.Get(helmRelease.Namespace, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = h.configMaps.Create(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: helmRelease.Namespace,
+				Labels: map[string]string{
+					InventoryOf: helmRelease.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					inventoryOwnerReference(helmRelease),
+				},
+			},
+			Data: map[string]string{
+				InventoryConfigMapKey: string(data),
+			},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if !isInventoryOf(configMap, helmRelease) {
+		return fmt.Errorf("unable to store inventory in ConfigMap %s/%s: ConfigMap already exists and is not the inventory of HelmRelease %s", configMap.Namespace, configMap.Name, helmRelease.Name)
+	}
+	owned := isOwnedBy(configMap, helmRelease)
+	if owned && configMap.Data[InventoryConfigMapKey] == string(data) {
+		return nil
+	}
+	configMap = configMap.DeepCopy()
+	if !owned {
+		// the HelmRelease may have been re-created, so the ConfigMap is adopted to be garbage collected along with it
+		var refs []metav1.OwnerReference
+		for _, ref := range configMap.OwnerReferences {
+			if ref.Kind == "HelmRelease" && ref.Name == helmRelease.Name {
+				continue
+			}
+			refs = append(refs, ref)
+		}
+		configMap.OwnerReferences = append(refs, inventoryOwnerReference(helmRelease))
+	}
+	configMap.Data = map[string]string{
+		InventoryConfigMapKey: string(data),
+	}
+	_, err = h.configMaps.Update(configMap)
+	return err
+}
+
+// deleteInventoryConfigMap deletes the ConfigMap that contains the inventory of a HelmRelease, if it exists
+func (h *handler) deleteInventoryConfigMap(helmRelease *v1alpha1.HelmRelease) error {
+	if len(helmRelease.Status.Inventory.ConfigMap) == 0 {
+		// the inventory was not stored in a ConfigMap
+		return nil
+	}
+	configMap, err := h.configMaps.Get(helmRelease.Namespace, inventoryConfigMapName(helmRelease), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isInventoryOf(configMap, helmRelease) {
+		return fmt.Errorf("unable to delete inventory ConfigMap %s/%s: ConfigMap is not the inventory of HelmRelease %s", configMap.Namespace, configMap.Name, helmRelease.Name)
+	}
+	err = h.configMaps.Delete(configMap.Namespace, configMap.Name, &metav1.DeleteOptions{
+		// ensures that a ConfigMap re-created since it was checked is not deleted
+		Preconditions: &metav1.Preconditions{UID: &configMap.UID},
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// inventoryOwnerReference returns the ownerReference set on the ConfigMap that contains the inventory of a HelmRelease,
+// so that it is garbage collected once the HelmRelease is deleted
+func inventoryOwnerReference(helmRelease *v1alpha1.HelmRelease) metav1.OwnerReference {
+	return *metav1.NewControllerRef(helmRelease, v1alpha1.SchemeGroupVersion.WithKind("HelmRelease"))
+}
+
+// isOwnedBy returns whether a ConfigMap has an ownerReference to a HelmRelease
+func isOwnedBy(configMap *corev1.ConfigMap, helmRelease *v1alpha1.HelmRelease) bool {
+	for _, ref := range configMap.OwnerReferences {
+		if ref.UID == helmRelease.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// isInventoryOf returns whether a ConfigMap contains the inventory of a HelmRelease, which is the case if it is labeled
+// as its inventory or owned by it
+//
+// Since the name of the ConfigMap is derived from the name of the HelmRelease, this prevents overwriting or deleting a
+// ConfigMap that happens to have the same name
+func isInventoryOf(configMap *corev1.ConfigMap, helmRelease *v1alpha1.HelmRelease) bool {
+	if configMap.Labels[InventoryOf] == helmRelease.Name {
+		return true
+	}
+	return isOwnedBy(configMap, helmRelease)
+}
+
+// trackedKinds returns the kinds of objects that have been tracked by a HelmRelease, including the kinds of the provided entries
+//
+// Kinds are never removed so that objects left behind by previous versions of the Helm release are still purged
+func trackedKinds(kinds []v1alpha1.TypeReference, entries []v1alpha1.InventoryEntry) []v1alpha1.TypeReference {
+	seen := make(map[v1alpha1.TypeReference]bool, len(kinds))
+	tracked := make([]v1alpha1.TypeReference, 0, len(kinds))
+	add := func(kind v1alpha1.TypeReference) {
+		if seen[kind] {
+			return
+		}
+		seen[kind] = true
+		tracked = append(tracked, kind)
+	}
+	for _, kind := range kinds {
+		add(kind)
+	}
+	for _, entry := range entries {
+		add(v1alpha1.TypeReference{APIVersion: entry.APIVersion, Kind: entry.Kind})
+	}
+	sort.Slice(tracked, func(i, j int) bool {
+		if tracked[i].APIVersion != tracked[j].APIVersion {
+			return tracked[i].APIVersion < tracked[j].APIVersion
+		}
+		return tracked[i].Kind < tracked[j].Kind
+	})
+	if len(tracked) == 0 {
+		return nil
+	}
+	return tracked
+}
+
+// trackedGVKs returns the GVKs of objects that have been tracked by a HelmRelease
+//
+// The entries of the inventory are also considered in case the kinds were not yet recorded on the HelmRelease
+func (h *handler) trackedGVKs(helmRelease *v1alpha1.HelmRelease) ([]schema.GroupVersionKind, error) {
+	entries, err := h.loadInventory(helmRelease)
+	if err != nil {
+		return nil, err
+	}
+	kinds := trackedKinds(helmRelease.Status.Inventory.Kinds, entries)
+	gvks := make([]schema.GroupVersionKind, 0, len(kinds))
+	for _, kind := range kinds {
+		gvks = append(gvks, schema.FromAPIVersionAndKind(kind.APIVersion, kind.Kind))
+	}
+	return gvks, nil
+}
+
+// setInventory sets the inventory of a HelmRelease
+func setInventory(status *v1alpha1.HelmReleaseStatus, inventory v1alpha1.Inventory) {
+	status.Inventory = inventory
+}
+
+// unlockInventory marks every object in the inventory of a HelmRelease as unlocked
+func (h *handler) unlockInventory(helmRelease *v1alpha1.HelmRelease) error {
+	entries, err := h.loadInventory(helmRelease)
+	if err != nil {
+		return err
+	}
+	unlocked := make([]v1alpha1.InventoryEntry, len(entries))
+	for i, entry := range entries {
+		entry.State = v1alpha1.UnlockedObjectState
+		unlocked[i] = entry
+	}
+	inventory, err := h.storeInventory(helmRelease, unlocked)
+	if err != nil {
+		return err
+	}
+	return h.updateStatus(helmRelease, func(status *v1alpha1.HelmReleaseStatus) {
+		setInventory(status, inventory)
+	})
+}
+
+// compress returns the base64-encoded gzipped data
+func compress(data []byte) (string, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decompress returns the data that was compressed by compress
+func decompress(compressed string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(compressed)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,54 @@
+package release
+
+import (
+	"testing"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsInventoryOf(t *testing.T) {
+	helmRelease := &v1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "foochart", UID: "1234"},
+	}
+	testCases := []struct {
+		name      string
+		configMap *corev1.ConfigMap
+		expected  bool
+	}{
+		{
+			name: "labeled",
+			configMap: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{InventoryOf: "foochart"},
+			}},
+			expected: true,
+		},
+		{
+			name: "owned",
+			configMap: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				OwnerReferences: []metav1.OwnerReference{inventoryOwnerReference(helmRelease)},
+			}},
+			expected: true,
+		},
+		{
+			name: "labeled for another HelmRelease",
+			configMap: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{InventoryOf: "barchart"},
+			}},
+			expected: false,
+		},
+		{
+			name:      "unrelated",
+			configMap: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foochart-inventory"}},
+			expected:  false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if isInventoryOf(tc.configMap, helmRelease) != tc.expected {
+				t.Errorf("expected isInventoryOf to return %t", tc.expected)
+			}
+		})
+	}
+}
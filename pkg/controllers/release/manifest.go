@@ -0,0 +1,148 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/helm-locker/pkg/objectset/parser"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	wranglerobjectset "github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// parseRelease parses the objects of a Helm release that are locked by a HelmRelease into an ObjectSet along with the
+// templates that rendered them
+//
+// Besides the manifest of the release, this includes the resources of persistent hooks if spec.includeHooks is set and
+// the CustomResourceDefinitions in the crds/ directory of the chart if spec.lockCRDs is set. Since Helm never deletes
+// these CustomResourceDefinitions, they are annotated with helm.sh/resource-policy: keep so that they are never purged.
+// If spec.subcharts is set, only the objects rendered by the selected subcharts are returned.
+//
+// The objects of the release that are not locked are returned in a separate ObjectSet, so that they are released from
+// the HelmRelease rather than pruned if they were locked before.
+func (h *handler) parseRelease(helmRelease *v1alpha1.HelmRelease, releaseKey relatedresource.Key, releaseInfo *releaseInfo) (*wranglerobjectset.ObjectSet, *wranglerobjectset.ObjectSet, objectset.Sources, error) {
+	opts := parser.Options{
+		Namespace:          releaseKey.Namespace,
+		RESTMapper:         h.restMapper,
+		APIVersionMappings: h.apiVersionMappings,
+	}
+	released := wranglerobjectset.NewObjectSet()
+	manifest := releaseInfo.Manifest
+	if helmRelease.Spec.IncludeHooks {
+		manifest = manifest + "\n" + releaseInfo.HookManifest
+	} else {
+		released = released.Add(parseReleased(releaseInfo.HookManifest, opts).All()...)
+	}
+	manifestOS, sources, err := parser.Parse(manifest, opts)
+	if !helmRelease.Spec.LockCRDs {
+		// CustomResourceDefinitions that were locked before must never be pruned, since that deletes their custom resources
+		released = released.Add(parseReleased(releaseInfo.CRDManifest, opts).All()...)
+		selected, deselected := selectSubcharts(helmRelease.Spec.Subcharts, manifestOS, sources)
+		return selected, released.Add(deselected.All()...), sources, err
+	}
+	crdOS, crdSources, crdErr := parser.Parse(releaseInfo.CRDManifest, opts)
+	for _, crd := range crdOS.All() {
+		u, ok := crd.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		annotations := u.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[objectset.ResourcePolicyAnnotation] = objectset.KeepResourcePolicy
+		u.SetAnnotations(annotations)
+		if source := crdSources.For(crd); len(source) > 0 {
+			if err := sources.Add(crd, source); err != nil {
+				crdErr = errors.Join(crdErr, err)
+			}
+		}
+	}
+	manifestOS = manifestOS.Add(crdOS.All()...)
+	selected, deselected := selectSubcharts(helmRelease.Spec.Subcharts, manifestOS, sources)
+	return selected, released.Add(deselected.All()...), sources, errors.Join(err, crdErr)
+}
+
+// parseReleased parses the objects of a manifest that are not locked by a HelmRelease
+//
+// Documents that cannot be parsed are skipped without reporting them, since these objects are never locked
+func parseReleased(manifest string, opts parser.Options) *wranglerobjectset.ObjectSet {
+	os, _, err := parser.Parse(manifest, opts)
+	if err != nil {
+		logrus.Debugf("unable to parse all objects that are not locked, skipping them: %s", err)
+	}
+	return os
+}
+
+// setManifestParsed sets the ManifestParsed condition of a HelmRelease based on the error returned on parsing its manifest
+func setManifestParsed(status *v1alpha1.HelmReleaseStatus, parseErr error) {
+	parsed := condition.Cond(v1alpha1.ManifestParsedCondition)
+	if parseErr == nil {
+		parsed.True(status)
+		parsed.Reason(status, "")
+		parsed.Message(status, "")
+		return
+	}
+	errs := flattenErrors(parseErr)
+	parsed.False(status)
+	parsed.Reason(status, "InvalidManifest")
+	parsed.Message(status, fmt.Sprintf("unable to parse %d document(s) of the manifest, their objects are not locked: %s", len(errs), describeErrors(errs)))
+}
+
+// setAPIVersionsServed sets the APIVersionsServed condition of a HelmRelease based on the objects in its manifest whose
+// apiVersion is not served by the cluster
+func setAPIVersionsServed(status *v1alpha1.HelmReleaseStatus, unserved []error) {
+	served := condition.Cond(v1alpha1.APIVersionsServedCondition)
+	if len(unserved) == 0 {
+		served.True(status)
+		served.Reason(status, "")
+		served.Message(status, "")
+		return
+	}
+	served.False(status)
+	served.Reason(status, "UnservedAPIVersion")
+	served.Message(status, fmt.Sprintf("%d object(s) are not locked: %s", len(unserved), describeErrors(unserved)))
+}
+
+// describeErrors returns a description of the first maxDescriptionsInMessage errors
+func describeErrors(errs []error) string {
+	return describeFirst(len(errs), func(i int) string {
+		return errs[i].Error()
+	})
+}
+
+// splitUnservedErrors splits each parser.UnservedError out of an error returned by parser.Parse, since objects whose
+// apiVersion is not served are skipped rather than preventing the rest of the release from being locked
+func splitUnservedErrors(parseErr error) ([]error, error) {
+	var unserved []error
+	var rest []error
+	for _, err := range flattenErrors(parseErr) {
+		var unservedErr *parser.UnservedError
+		if errors.As(err, &unservedErr) {
+			unserved = append(unserved, err)
+			continue
+		}
+		rest = append(rest, err)
+	}
+	return unserved, errors.Join(rest...)
+}
+
+// flattenErrors returns each error joined in an error returned by parser.Parse (e.g. a parser.DocumentError)
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(err)...)
+	}
+	return errs
+}
@@ -0,0 +1,59 @@
+package release
+
+import (
+	"strings"
+	"testing"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset/parser"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+)
+
+func TestSetManifestParsed(t *testing.T) {
+	manifest := `---
+# Source: foochart/templates/good.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: good
+  namespace: default
+---
+# Source: foochart/templates/bad.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata: [
+`
+	os, _, err := parser.Parse(manifest, parser.Options{})
+	if err == nil {
+		t.Fatal("expected the manifest to not be fully parsed")
+	}
+	if len(os.All()) != 1 {
+		t.Errorf("expected the objects that were parsed to be returned, got %d objects", len(os.All()))
+	}
+
+	status := &v1alpha1.HelmReleaseStatus{}
+	setManifestParsed(status, err)
+	parsed := condition.Cond(v1alpha1.ManifestParsedCondition)
+	if !parsed.IsFalse(status) || parsed.GetReason(status) != "InvalidManifest" {
+		t.Errorf("expected the manifest to not be parsed, got %s: %s", parsed.GetStatus(status), parsed.GetReason(status))
+	}
+	if message := parsed.GetMessage(status); !strings.Contains(message, "document 1 (foochart/templates/bad.yaml)") {
+		t.Errorf("expected the index and source of the document that could not be parsed to be reported, got %q", message)
+	}
+
+	setManifestParsed(status, nil)
+	if !parsed.IsTrue(status) || len(parsed.GetMessage(status)) != 0 {
+		t.Errorf("expected the manifest to be parsed, got %s: %s", parsed.GetStatus(status), parsed.GetMessage(status))
+	}
+}
+
+func TestDescribeFirst(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f", "g"}
+	describe := func(i int) string { return items[i] }
+	if description := describeFirst(maxDescriptionsInMessage, describe); description != "a; b; c; d; e" {
+		t.Errorf("expected every item to be described, got %q", description)
+	}
+	if description := describeFirst(len(items), describe); description != "a; b; c; d; e; and 2 more" {
+		t.Errorf("expected the items after the first %d to be summarized, got %q", maxDescriptionsInMessage, description)
+	}
+}
@@ -0,0 +1,150 @@
+package release
+
+import (
+	"fmt"
+	"time"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ConfirmPurgeAnnotation is an annotation that confirms that the objects of a Helm release whose release secret was not
+	// found can be purged if its HelmRelease requires confirmation or tracks more objects than can be purged without it
+	ConfirmPurgeAnnotation = "helmreleases.cattle.io/confirm-purge"
+
+	// DefaultPurgeGracePeriod is the default time that a Helm release secret must not be found for before its objects are purged
+	// Objects are purged as soon as the release secret is not found by default, as they were before grace periods existed
+	DefaultPurgeGracePeriod = 0
+)
+
+// PurgeOptions configure when the objects of a Helm release whose release secret was not found are purged
+type PurgeOptions struct {
+	// GracePeriod is the time that a Helm release secret must not be found for before its objects are purged
+	GracePeriod time.Duration
+	// MaxObjects is the maximum number of objects that are purged without confirmation; 0 disables the limit
+	MaxObjects int
+}
+
+// Validate returns an error if the PurgeOptions are invalid
+func (o PurgeOptions) Validate() error {
+	if o.GracePeriod < 0 {
+		return fmt.Errorf("invalid purge grace period %s: must not be negative", o.GracePeriod)
+	}
+	if o.MaxObjects < 0 {
+		return fmt.Errorf("invalid max purge objects %d: must not be negative", o.MaxObjects)
+	}
+	return nil
+}
+
+// onReleaseNotFound purges the objects of a HelmRelease whose Helm release secret was not found based on its deletion
+// policy, reporting a pending purge in the PurgePending condition of the HelmRelease until it happens
+func (h *handler) onReleaseNotFound(helmRelease *v1alpha1.HelmRelease, releaseKey relatedresource.Key) (*v1alpha1.HelmRelease, error) {
+	helmRelease.Status.Version = 0
+	helmRelease.Status.Description = "Could not find Helm Release Secret"
+	helmRelease.Status.State = v1alpha1.SecretNotFoundState
+	helmRelease.Status.Notes = ""
+
+	pendingPurge := condition.Cond(v1alpha1.PurgePendingCondition)
+	if pendingPurge.IsFalse(&helmRelease.Status) && len(pendingPurge.GetReason(&helmRelease.Status)) > 0 {
+		// the objects of the Helm release were already purged or orphaned
+		return h.helmReleases.UpdateStatus(helmRelease)
+	}
+
+	deletionPolicy := helmRelease.Spec.DeletionPolicy
+	if len(deletionPolicy) == 0 {
+		deletionPolicy = v1alpha1.PurgeDeletionPolicy
+	}
+	switch deletionPolicy {
+	case v1alpha1.PurgeDeletionPolicy, v1alpha1.ConfirmByAnnotationDeletionPolicy:
+	case v1alpha1.OrphanDeletionPolicy:
+		logrus.Warnf("release %s/%s of HelmRelease %s was not found, leaving its objects in the cluster since its deletion policy is %s", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName(), deletionPolicy)
+		h.lockableObjectSetRegister.Delete(releaseKey) // remove the objectset, but don't purge the underlying resources
+		helmRelease.Status.PurgeAfter = ""
+		setPurgePending(&helmRelease.Status, false, "Orphaned", fmt.Sprintf("objects of the Helm release were left in the cluster since the deletion policy is %s", deletionPolicy))
+		h.recorder.Eventf(helmRelease, corev1.EventTypeNormal, "Orphaned", "Left objects of Helm release %s/%s in the cluster since its release secret was not found", releaseKey.Namespace, releaseKey.Name)
+		return h.helmReleases.UpdateStatus(helmRelease)
+	default:
+		return helmRelease, fmt.Errorf("invalid deletion policy %q on HelmRelease %s: must be one of %s, %s, or %s", deletionPolicy, helmRelease.GetName(), v1alpha1.PurgeDeletionPolicy, v1alpha1.OrphanDeletionPolicy, v1alpha1.ConfirmByAnnotationDeletionPolicy)
+	}
+
+	// stop reverting changes to the objects of the Helm release while the purge is pending
+	h.lockableObjectSetRegister.Unlock(releaseKey)
+
+	now := time.Now()
+	purgeAfter, err := time.Parse(time.RFC3339, helmRelease.Status.PurgeAfter)
+	if err != nil {
+		purgeAfter = now.Add(h.purgeOptions.GracePeriod)
+		if h.purgeOptions.GracePeriod > 0 {
+			helmRelease.Status.PurgeAfter = purgeAfter.UTC().Format(time.RFC3339)
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "PurgePending", "Release secret of Helm release %s/%s was not found, purging its objects after %s unless it is restored", releaseKey.Namespace, releaseKey.Name, helmRelease.Status.PurgeAfter)
+		}
+	}
+	numObjects := helmRelease.Status.Inventory.Count
+	confirmed := helmRelease.Annotations[ConfirmPurgeAnnotation] == "true"
+	switch {
+	case now.Before(purgeAfter):
+		logrus.Warnf("release %s/%s of HelmRelease %s was not found, purging its objects after %s", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName(), helmRelease.Status.PurgeAfter)
+		setPurgePending(&helmRelease.Status, true, "GracePeriod", fmt.Sprintf("release secret was not found, purging %d objects after %s unless it is restored", numObjects, helmRelease.Status.PurgeAfter))
+		h.helmReleases.EnqueueAfter(helmRelease.Namespace, helmRelease.Name, purgeAfter.Sub(now))
+		return h.helmReleases.UpdateStatus(helmRelease)
+	case deletionPolicy == v1alpha1.ConfirmByAnnotationDeletionPolicy && !confirmed:
+		logrus.Warnf("release %s/%s of HelmRelease %s was not found, waiting for confirmation to purge its objects", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName())
+		setPurgePending(&helmRelease.Status, true, "AwaitingConfirmation", fmt.Sprintf("release secret was not found, annotate the HelmRelease with %s=true to purge %d objects", ConfirmPurgeAnnotation, numObjects))
+		return h.helmReleases.UpdateStatus(helmRelease)
+	case h.purgeOptions.MaxObjects > 0 && numObjects > h.purgeOptions.MaxObjects && !confirmed:
+		logrus.Warnf("release %s/%s of HelmRelease %s was not found, refusing to purge %d objects without confirmation", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName(), numObjects)
+		message := fmt.Sprintf("release secret was not found, but purging %d objects exceeds the limit of %d objects, annotate the HelmRelease with %s=true to purge them", numObjects, h.purgeOptions.MaxObjects, ConfirmPurgeAnnotation)
+		if !pendingPurge.IsTrue(&helmRelease.Status) || pendingPurge.GetReason(&helmRelease.Status) != "TooManyObjects" {
+			h.recorder.Event(helmRelease, corev1.EventTypeWarning, "PurgeRefused", message)
+		}
+		setPurgePending(&helmRelease.Status, true, "TooManyObjects", message)
+		return h.helmReleases.UpdateStatus(helmRelease)
+	}
+
+	purgeGVKs, err := h.trackedGVKs(helmRelease)
+	if err != nil {
+		return helmRelease, fmt.Errorf("unable to identify kinds of objects tracked by HelmRelease %s to purge: %s", helmRelease.GetName(), err)
+	}
+	if len(purgeGVKs) == 0 {
+		// HelmReleases created before kinds were recorded in the inventory fall back to the objectset that was last set
+		purgeGVKs = h.lockableObjectSetRegister.GVKs(releaseKey)
+	}
+	if len(purgeGVKs) == 0 {
+		logrus.Warnf("release %s/%s of HelmRelease %s was not found, refusing to purge its objects since their kinds are unknown", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName())
+		message := fmt.Sprintf("release secret was not found, but the kinds of objects to purge were never recorded in the inventory, delete them manually and set the deletion policy to %s to stop tracking them", v1alpha1.OrphanDeletionPolicy)
+		if !pendingPurge.IsTrue(&helmRelease.Status) || pendingPurge.GetReason(&helmRelease.Status) != "UnknownKinds" {
+			h.recorder.Event(helmRelease, corev1.EventTypeWarning, "PurgeRefused", message)
+		}
+		setPurgePending(&helmRelease.Status, true, "UnknownKinds", message)
+		return h.helmReleases.UpdateStatus(helmRelease)
+	}
+
+	logrus.Warnf("release %s/%s of HelmRelease %s was not found, deleting any orphaned resources", releaseKey.Namespace, releaseKey.Name, helmRelease.GetName())
+	h.lockableObjectSetRegister.Purge(releaseKey, purgeGVKs) // remove the objectset and purge any untracked resources
+	helmRelease.Status.PurgeAfter = ""
+	setPurgePending(&helmRelease.Status, false, "Purged", fmt.Sprintf("purged %d objects since the release secret was not found", numObjects))
+	h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "Purged", "Purged %d objects of Helm release %s/%s since its release secret was not found", numObjects, releaseKey.Namespace, releaseKey.Name)
+	return h.helmReleases.UpdateStatus(helmRelease)
+}
+
+// setPurgePending sets the PurgePending condition of a HelmRelease
+func setPurgePending(status *v1alpha1.HelmReleaseStatus, pending bool, reason, message string) {
+	pendingPurge := condition.Cond(v1alpha1.PurgePendingCondition)
+	pendingPurge.SetStatusBool(status, pending)
+	pendingPurge.Reason(status, reason)
+	pendingPurge.Message(status, message)
+}
+
+// clearPurgePending resets the PurgePending condition of a HelmRelease whose Helm release secret was found
+func clearPurgePending(status *v1alpha1.HelmReleaseStatus) {
+	status.PurgeAfter = ""
+	pendingPurge := condition.Cond(v1alpha1.PurgePendingCondition)
+	if len(pendingPurge.GetStatus(status)) == 0 {
+		return
+	}
+	setPurgePending(status, false, "", "")
+}
@@ -0,0 +1,125 @@
+package release
+
+import (
+	"testing"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	helmcontroller "github.com/rancher/helm-locker/pkg/generated/controllers/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeRegister is an objectset.LockableRegister that records the GVKs that are purged
+type fakeRegister struct {
+	objectset.LockableRegister
+
+	// gvks are the GVKs of the objectset currently set for any key
+	gvks []schema.GroupVersionKind
+	// purged indicates whether the objectset was purged
+	purged bool
+	// purgedGVKs are the GVKs that were purged
+	purgedGVKs []schema.GroupVersionKind
+}
+
+func (r *fakeRegister) Unlock(relatedresource.Key) {}
+
+func (r *fakeRegister) GVKs(relatedresource.Key) []schema.GroupVersionKind { return r.gvks }
+
+func (r *fakeRegister) Purge(_ relatedresource.Key, gvks []schema.GroupVersionKind) {
+	r.purged = true
+	r.purgedGVKs = gvks
+}
+
+// fakeHelmReleases is a helmcontroller.HelmReleaseController that returns the HelmReleases whose status is updated
+type fakeHelmReleases struct {
+	helmcontroller.HelmReleaseController
+}
+
+func (fakeHelmReleases) UpdateStatus(helmRelease *v1alpha1.HelmRelease) (*v1alpha1.HelmRelease, error) {
+	return helmRelease, nil
+}
+
+func TestPurgeKinds(t *testing.T) {
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	releaseKey := relatedresource.Key{Namespace: "default", Name: "foochart"}
+	testCases := []struct {
+		name string
+		// kinds are the kinds recorded in the inventory of the HelmRelease
+		kinds []v1alpha1.TypeReference
+		// gvks are the GVKs of the objectset currently set for the Helm release
+		gvks           []schema.GroupVersionKind
+		expectedGVKs   []schema.GroupVersionKind
+		expectedReason string
+	}{
+		{
+			name:           "kinds recorded in the inventory",
+			kinds:          []v1alpha1.TypeReference{{APIVersion: "v1", Kind: "ConfigMap"}},
+			gvks:           []schema.GroupVersionKind{deploymentGVK},
+			expectedGVKs:   []schema.GroupVersionKind{configMapGVK},
+			expectedReason: "Purged",
+		},
+		{
+			name:           "empty inventory falls back to the objectset",
+			gvks:           []schema.GroupVersionKind{deploymentGVK},
+			expectedGVKs:   []schema.GroupVersionKind{deploymentGVK},
+			expectedReason: "Purged",
+		},
+		{
+			name:           "empty inventory without an objectset",
+			expectedReason: "UnknownKinds",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			register := &fakeRegister{gvks: tc.gvks}
+			recorder := record.NewFakeRecorder(10)
+			h := &handler{
+				helmReleases:              fakeHelmReleases{},
+				lockableObjectSetRegister: register,
+				recorder:                  recorder,
+			}
+			helmRelease := &v1alpha1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-helm-system", Name: "foochart"},
+				Status:     v1alpha1.HelmReleaseStatus{Inventory: v1alpha1.Inventory{Count: 3, Kinds: tc.kinds}},
+			}
+			helmRelease, err := h.onReleaseNotFound(helmRelease, releaseKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pendingPurge := condition.Cond(v1alpha1.PurgePendingCondition)
+			if reason := pendingPurge.GetReason(&helmRelease.Status); reason != tc.expectedReason {
+				t.Errorf("expected PurgePending reason %s, got %s: %s", tc.expectedReason, reason, pendingPurge.GetMessage(&helmRelease.Status))
+			}
+			if register.purged != (len(tc.expectedGVKs) > 0) {
+				t.Fatalf("expected the objects to be purged: %t, got %t", len(tc.expectedGVKs) > 0, register.purged)
+			}
+			if len(register.purgedGVKs) != len(tc.expectedGVKs) {
+				t.Fatalf("expected %v to be purged, got %v", tc.expectedGVKs, register.purgedGVKs)
+			}
+			for i := range tc.expectedGVKs {
+				if register.purgedGVKs[i] != tc.expectedGVKs[i] {
+					t.Errorf("expected %v to be purged, got %v", tc.expectedGVKs, register.purgedGVKs)
+				}
+			}
+			if len(recorder.Events) != 1 {
+				t.Errorf("expected a single event, got %d", len(recorder.Events))
+			}
+
+			// a refused purge is not reported again when the HelmRelease is reconciled
+			if !register.purged {
+				if _, err := h.onReleaseNotFound(helmRelease, releaseKey); err != nil {
+					t.Fatal(err)
+				}
+				if len(recorder.Events) != 1 || !pendingPurge.IsTrue(&helmRelease.Status) {
+					t.Errorf("expected the purge to stay pending without another event, got %d events", len(recorder.Events))
+				}
+			}
+		})
+	}
+}
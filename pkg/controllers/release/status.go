@@ -0,0 +1,86 @@
+package release
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/wrangler/v3/pkg/gvk"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// maxDescriptionsInMessage is the maximum number of items (e.g. errors or conflicts) described in a condition or
+	// event of a HelmRelease
+	maxDescriptionsInMessage = 5
+)
+
+// describeFirst returns a description of the first maxDescriptionsInMessage of n items, summarizing the rest
+func describeFirst(n int, describe func(i int) string) string {
+	var descriptions []string
+	for i := 0; i < n; i++ {
+		if i == maxDescriptionsInMessage {
+			descriptions = append(descriptions, fmt.Sprintf("and %d more", n-maxDescriptionsInMessage))
+			break
+		}
+		descriptions = append(descriptions, describe(i))
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// updateStatus applies the mutate function to the latest version of a HelmRelease and persists its status if it changed
+// This allows handlers that do not run within the HelmRelease controller to safely update its status
+func (h *handler) updateStatus(helmRelease *v1alpha1.HelmRelease, mutate func(status *v1alpha1.HelmReleaseStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := h.helmReleases.Get(helmRelease.Namespace, helmRelease.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		updated := latest.DeepCopy()
+		mutate(&updated.Status)
+		if reflect.DeepEqual(latest.Status, updated.Status) {
+			return nil
+		}
+		_, err = h.helmReleases.UpdateStatus(updated)
+		return err
+	})
+}
+
+// objectKeyString returns the namespace/name of the object referenced, or just its name if it is not namespaced
+func objectKeyString(ref v1alpha1.ObjectReference) string {
+	if len(ref.Namespace) == 0 {
+		return ref.Name
+	}
+	return fmt.Sprintf("%s/%s", ref.Namespace, ref.Name)
+}
+
+// renderedBy returns a suffix for event messages that identifies the template that rendered an object, if known
+func renderedBy(source string) string {
+	if len(source) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (rendered by %s)", source)
+}
+
+// objectReference returns a reference to an object tracked by a Helm release
+func objectReference(obj runtime.Object) (v1alpha1.ObjectReference, error) {
+	metadata, err := meta.Accessor(obj)
+	if err != nil {
+		return v1alpha1.ObjectReference{}, err
+	}
+	objGVK, err := gvk.Get(obj)
+	if err != nil {
+		return v1alpha1.ObjectReference{}, err
+	}
+	apiVersion, kind := objGVK.ToAPIVersionAndKind()
+	return v1alpha1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  metadata.GetNamespace(),
+		Name:       metadata.GetName(),
+	}, nil
+}
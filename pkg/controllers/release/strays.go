@@ -0,0 +1,73 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// maxStraysInMessage is the maximum number of stray objects described in the StrayObjects condition of a HelmRelease
+	maxStraysInMessage = 5
+)
+
+// onStrays emits events for the stray objects found on locking a release
+func (h *handler) onStrays(helmRelease *v1alpha1.HelmRelease, strays []objectset.Stray) error {
+	dryRun := helmRelease.Spec.StrayObjects != nil && helmRelease.Spec.StrayObjects.DryRun
+	for _, stray := range strays {
+		ref, err := objectReference(stray.Object)
+		if err != nil {
+			return err
+		}
+		switch {
+		case stray.Pruned:
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "PrunedStrayObject", "Deleted %s %s since it claims to belong to the Helm release but is not in its manifest", ref.Kind, objectKeyString(ref))
+		case dryRun:
+			h.recorder.Eventf(stray.Object, corev1.EventTypeWarning, "StrayObject", "Would be deleted since it claims to belong to the Helm release locked by HelmRelease %s/%s but is not in its manifest (dry run)", helmRelease.Namespace, helmRelease.Name)
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "StrayObject", "Would delete %s %s since it claims to belong to the Helm release but is not in its manifest (dry run)", ref.Kind, objectKeyString(ref))
+		default:
+			h.recorder.Eventf(stray.Object, corev1.EventTypeWarning, "StrayObject", "Claims to belong to the Helm release locked by HelmRelease %s/%s but is not in its manifest", helmRelease.Namespace, helmRelease.Name)
+			h.recorder.Eventf(helmRelease, corev1.EventTypeWarning, "StrayObject", "Found %s %s that claims to belong to the Helm release but is not in its manifest", ref.Kind, objectKeyString(ref))
+		}
+	}
+	return nil
+}
+
+// setStrayObjects sets the StrayObjects condition of a HelmRelease based on the stray objects found on locking its release
+//
+// Stray objects that were pruned are no longer reported once they are deleted
+func setStrayObjects(status *v1alpha1.HelmReleaseStatus, strays []objectset.Stray) {
+	found := condition.Cond(v1alpha1.StrayObjectsCondition)
+	var descriptions []string
+	remaining := 0
+	for _, stray := range strays {
+		if stray.Pruned {
+			continue
+		}
+		remaining++
+		if len(descriptions) == maxStraysInMessage {
+			continue
+		}
+		ref, err := objectReference(stray.Object)
+		if err != nil {
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s %s", ref.Kind, objectKeyString(ref)))
+	}
+	if remaining == 0 {
+		found.False(status)
+		found.Reason(status, "")
+		found.Message(status, "")
+		return
+	}
+	if remaining > len(descriptions) {
+		descriptions = append(descriptions, fmt.Sprintf("and %d more", remaining-len(descriptions)))
+	}
+	found.True(status)
+	found.Reason(status, "NotInManifest")
+	found.Message(status, fmt.Sprintf("%d object(s) claim to belong to the Helm release but are not in its manifest: %s", remaining, strings.Join(descriptions, "; ")))
+}
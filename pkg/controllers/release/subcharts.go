@@ -0,0 +1,61 @@
+package release
+
+import (
+	"strings"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	wranglerobjectset "github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/sirupsen/logrus"
+)
+
+// subchartsOf returns the names of the subcharts that contain the template at source, from the outermost to the innermost
+// (e.g. security and nested for umbrella/charts/security/charts/nested/templates/role.yaml), or nil if it was rendered
+// by the chart itself
+func subchartsOf(source string) []string {
+	parts := strings.Split(source, "/")
+	var subcharts []string
+	for i := 1; i+2 < len(parts) && parts[i] == "charts"; i += 2 {
+		subcharts = append(subcharts, parts[i+1])
+	}
+	return subcharts
+}
+
+// selectSubcharts splits the objects of an ObjectSet into those rendered by the selected subcharts and those that are not
+//
+// The objects that are not selected are still part of the release, so they are released from the HelmRelease rather than
+// pruned if they were locked before.
+func selectSubcharts(subcharts *v1alpha1.Subcharts, os *wranglerobjectset.ObjectSet, sources objectset.Sources) (*wranglerobjectset.ObjectSet, *wranglerobjectset.ObjectSet) {
+	deselected := wranglerobjectset.NewObjectSet()
+	if subcharts == nil || (len(subcharts.Include) == 0 && len(subcharts.Exclude) == 0) {
+		return os, deselected
+	}
+	include := toSet(subcharts.Include)
+	exclude := toSet(subcharts.Exclude)
+	selected := wranglerobjectset.NewObjectSet()
+	for _, obj := range os.All() {
+		// objects of nested subcharts are selected by the name of any subchart that contains them
+		included := len(include) == 0
+		excluded := false
+		for _, subchart := range subchartsOf(sources.For(obj)) {
+			included = included || include[subchart]
+			excluded = excluded || exclude[subchart]
+		}
+		if !included || excluded {
+			deselected.Add(obj)
+			continue
+		}
+		selected.Add(obj)
+	}
+	logrus.Debugf("selected %d of %d objects rendered by subcharts (include: %v, exclude: %v)", len(selected.All()), len(os.All()), subcharts.Include, subcharts.Exclude)
+	return selected, deselected
+}
+
+// toSet returns a set containing the provided values
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
@@ -0,0 +1,159 @@
+package release
+
+import (
+	"slices"
+	"sort"
+	"testing"
+
+	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	wranglerobjectset "github.com/rancher/wrangler/v3/pkg/objectset"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSubchartsOf(t *testing.T) {
+	testCases := []struct {
+		source    string
+		subcharts []string
+	}{
+		{source: "umbrella/templates/configmap.yaml", subcharts: nil},
+		{source: "umbrella/charts/security/templates/psp.yaml", subcharts: []string{"security"}},
+		{source: "umbrella/charts/security/charts/nested/templates/role.yaml", subcharts: []string{"security", "nested"}},
+		{source: "umbrella/charts/security/charts/nested/charts/deep/templates/role.yaml", subcharts: []string{"security", "nested", "deep"}},
+		{source: "umbrella/charts/templates.yaml", subcharts: nil},
+		{source: "umbrella/charts/security/charts/templates.yaml", subcharts: []string{"security"}},
+		{source: "", subcharts: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			if subcharts := subchartsOf(tc.source); !slices.Equal(subcharts, tc.subcharts) {
+				t.Errorf("expected subcharts %v, found %v", tc.subcharts, subcharts)
+			}
+		})
+	}
+}
+
+// newSubchartRelease returns an ObjectSet with a ConfigMap rendered by the umbrella chart and by each of its subcharts
+// along with the Sources of the ConfigMaps, which are named after the chart that rendered them
+func newSubchartRelease(t *testing.T) (*wranglerobjectset.ObjectSet, objectset.Sources) {
+	os := wranglerobjectset.NewObjectSet()
+	sources := objectset.Sources{}
+	for name, source := range map[string]string{
+		"umbrella": "umbrella/templates/configmap.yaml",
+		"logging":  "umbrella/charts/logging/templates/configmap.yaml",
+		"security": "umbrella/charts/security/templates/configmap.yaml",
+		"nested":   "umbrella/charts/security/charts/nested/templates/configmap.yaml",
+	} {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		os.Add(obj)
+		if err := sources.Add(obj, source); err != nil {
+			t.Fatalf("unable to add source of %s: %s", name, err)
+		}
+	}
+	return os, sources
+}
+
+// names returns the sorted names of the objects in an ObjectSet
+func names(objs []runtime.Object) []string {
+	var objNames []string
+	for _, obj := range objs {
+		objNames = append(objNames, obj.(*unstructured.Unstructured).GetName())
+	}
+	sort.Strings(objNames)
+	return objNames
+}
+
+func TestSelectSubcharts(t *testing.T) {
+	testCases := []struct {
+		name       string
+		subcharts  *v1alpha1.Subcharts
+		selected   []string
+		deselected []string
+	}{
+		{
+			name:     "no subcharts",
+			selected: []string{"logging", "nested", "security", "umbrella"},
+		},
+		{
+			name:      "empty subcharts",
+			subcharts: &v1alpha1.Subcharts{},
+			selected:  []string{"logging", "nested", "security", "umbrella"},
+		},
+		{
+			name:       "include",
+			subcharts:  &v1alpha1.Subcharts{Include: []string{"security"}},
+			selected:   []string{"nested", "security"},
+			deselected: []string{"logging", "umbrella"},
+		},
+		{
+			name:       "exclude",
+			subcharts:  &v1alpha1.Subcharts{Exclude: []string{"security"}},
+			selected:   []string{"logging", "umbrella"},
+			deselected: []string{"nested", "security"},
+		},
+		{
+			name:       "include and exclude",
+			subcharts:  &v1alpha1.Subcharts{Include: []string{"logging", "security"}, Exclude: []string{"security"}},
+			selected:   []string{"logging"},
+			deselected: []string{"nested", "security", "umbrella"},
+		},
+		{
+			name:       "include a nested subchart",
+			subcharts:  &v1alpha1.Subcharts{Include: []string{"nested"}},
+			selected:   []string{"nested"},
+			deselected: []string{"logging", "security", "umbrella"},
+		},
+		{
+			name:       "exclude a nested subchart",
+			subcharts:  &v1alpha1.Subcharts{Exclude: []string{"nested"}},
+			selected:   []string{"logging", "security", "umbrella"},
+			deselected: []string{"nested"},
+		},
+		{
+			name:       "exclude a nested subchart of an included subchart",
+			subcharts:  &v1alpha1.Subcharts{Include: []string{"security"}, Exclude: []string{"nested"}},
+			selected:   []string{"security"},
+			deselected: []string{"logging", "nested", "umbrella"},
+		},
+		{
+			name:       "objects of the parent chart are only selected without include",
+			subcharts:  &v1alpha1.Subcharts{Include: []string{"logging"}, Exclude: []string{"security"}},
+			selected:   []string{"logging"},
+			deselected: []string{"nested", "security", "umbrella"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os, sources := newSubchartRelease(t)
+			selected, deselected := selectSubcharts(tc.subcharts, os, sources)
+			if found := names(selected.All()); !slices.Equal(found, tc.selected) {
+				t.Errorf("expected selected objects %v, found %v", tc.selected, found)
+			}
+			if found := names(deselected.All()); !slices.Equal(found, tc.deselected) {
+				t.Errorf("expected deselected objects %v, found %v", tc.deselected, found)
+			}
+		})
+	}
+}
+
+// TestNarrowSubchartsReleasesObjects verifies that narrowing spec.subcharts never drops objects of the release, since
+// objects that are neither locked nor released would be pruned from the cluster
+func TestNarrowSubchartsReleasesObjects(t *testing.T) {
+	os, sources := newSubchartRelease(t)
+	for _, subcharts := range []*v1alpha1.Subcharts{
+		{Include: []string{"logging"}},
+		{Exclude: []string{"logging", "security"}},
+		{Include: []string{"unknown"}},
+	} {
+		selected, deselected := selectSubcharts(subcharts, os, sources)
+		all := names(append(selected.All(), deselected.All()...))
+		if expected := names(os.All()); !slices.Equal(all, expected) {
+			t.Errorf("expected subcharts %v to select or release every object %v, found %v", subcharts, expected, all)
+		}
+	}
+}
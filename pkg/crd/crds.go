@@ -9,6 +9,7 @@ import (
 	v1alpha1 "github.com/rancher/helm-locker/pkg/apis/helm.cattle.io/v1alpha1"
 	"github.com/rancher/wrangler/v3/pkg/crd"
 	"github.com/rancher/wrangler/v3/pkg/yaml"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
@@ -71,7 +72,14 @@ func List() []crd.CRD {
 				WithColumn("Release Name", ".spec.release.name").
 				WithColumn("Release Namespace", ".spec.release.namespace").
 				WithColumn("Version", ".status.version").
-				WithColumn("State", ".status.state")
+				WithColumn("State", ".status.state").
+				WithColumn("Healthy", `.status.conditions[?(@.type=="Healthy")].status`).
+				WithCustomColumn(apiextv1.CustomResourceColumnDefinition{
+					Name:     "Corrections",
+					Type:     "integer",
+					JSONPath: ".status.driftCorrections",
+				}).
+				WithColumn("Last Corrected", ".status.lastDriftCorrection")
 		}),
 	}
 }
@@ -0,0 +1,269 @@
+package health
+
+import (
+	"fmt"
+
+	"github.com/rancher/lasso/pkg/controller"
+	"github.com/rancher/wrangler/v3/pkg/gvk"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Status is a kstatus-style summary of the health of an object
+type Status string
+
+const (
+	// CurrentStatus indicates that the object is fully reconciled and healthy
+	CurrentStatus Status = "Current"
+
+	// InProgressStatus indicates that the object is still being reconciled
+	InProgressStatus Status = "InProgress"
+
+	// FailedStatus indicates that the object has failed to reconcile and requires intervention
+	FailedStatus Status = "Failed"
+)
+
+// Result is the health of a single object
+type Result struct {
+	// Object is the live object whose health was computed, or the desired object if it was not found in the cluster
+	Object runtime.Object
+	// Status is the health of the object
+	Status Status
+	// Message is a human-readable explanation of the Status
+	Message string
+}
+
+// Checker computes the health of objects from the caches of a SharedControllerFactory
+type Checker interface {
+	// Check returns the health of the live version of each of the provided objects
+	Check(objs []runtime.Object) ([]Result, error)
+}
+
+// NewChecker returns a Checker that looks up live objects from the caches of the provided SharedControllerFactory
+func NewChecker(scf controller.SharedControllerFactory) Checker {
+	return &checker{
+		scf: scf,
+	}
+}
+
+// checker implements Checker
+type checker struct {
+	scf controller.SharedControllerFactory
+}
+
+// Check returns the health of the live version of each of the provided objects
+func (c *checker) Check(objs []runtime.Object) ([]Result, error) {
+	results := make([]Result, 0, len(objs))
+	for _, obj := range objs {
+		objGVK, err := gvk.Get(obj)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		live, exists, err := c.get(objGVK, metadata.GetNamespace(), metadata.GetName())
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			results = append(results, Result{Object: obj, Status: InProgressStatus, Message: "object does not exist"})
+			continue
+		}
+		status, message, err := Compute(objGVK.GroupKind(), live)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, Result{Object: live, Status: status, Message: message})
+	}
+	return results, nil
+}
+
+// get returns the live version of an object from the cache of the SharedControllerFactory
+func (c *checker) get(objGVK schema.GroupVersionKind, namespace, name string) (runtime.Object, bool, error) {
+	sharedController, err := c.scf.ForKind(objGVK)
+	if err != nil {
+		return nil, false, err
+	}
+	key := name
+	if len(namespace) > 0 {
+		key = fmt.Sprintf("%s/%s", namespace, name)
+	}
+	obj, exists, err := sharedController.Informer().GetStore().GetByKey(key)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected object of type %T in cache for %s", obj, objGVK)
+	}
+	return runtimeObj, true, nil
+}
+
+// Compute returns the health of a live object of the provided GroupKind
+func Compute(gk schema.GroupKind, obj runtime.Object) (Status, string, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", "", err
+	}
+	if status, message, done := checkGeneration(u); done {
+		return status, message, nil
+	}
+	switch gk {
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}:
+		return deploymentStatus(u)
+	case schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		return statefulSetStatus(u)
+	case schema.GroupKind{Group: "apps", Kind: "DaemonSet"}:
+		return daemonSetStatus(u)
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return jobStatus(u)
+	case schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+		return pvcStatus(u)
+	}
+	return genericStatus(u)
+}
+
+// checkGeneration returns InProgress if the controller of the object has not yet observed its latest generation
+func checkGeneration(u map[string]interface{}) (Status, string, bool) {
+	generation, _, _ := unstructured.NestedInt64(u, "metadata", "generation")
+	observedGeneration, found, _ := unstructured.NestedInt64(u, "status", "observedGeneration")
+	if found && observedGeneration < generation {
+		return InProgressStatus, fmt.Sprintf("latest generation %d has not been observed yet", generation), true
+	}
+	return "", "", false
+}
+
+// deploymentStatus returns the Status of a Deployment
+func deploymentStatus(u map[string]interface{}) (Status, string, error) {
+	if condition, ok := getCondition(u, "Progressing"); ok && condition["reason"] == "ProgressDeadlineExceeded" {
+		return FailedStatus, fmt.Sprintf("progress deadline exceeded: %v", condition["message"]), nil
+	}
+	replicas := getInt(u, 1, "spec", "replicas")
+	updated := getInt(u, 0, "status", "updatedReplicas")
+	total := getInt(u, 0, "status", "replicas")
+	available := getInt(u, 0, "status", "availableReplicas")
+	ready := getInt(u, 0, "status", "readyReplicas")
+	switch {
+	case updated < replicas:
+		return InProgressStatus, fmt.Sprintf("%d/%d replicas updated", updated, replicas), nil
+	case total > updated:
+		return InProgressStatus, fmt.Sprintf("%d old replicas pending termination", total-updated), nil
+	case available < replicas:
+		return InProgressStatus, fmt.Sprintf("%d/%d replicas available", available, replicas), nil
+	case ready < replicas:
+		return InProgressStatus, fmt.Sprintf("%d/%d replicas ready", ready, replicas), nil
+	}
+	return CurrentStatus, fmt.Sprintf("%d/%d replicas available", available, replicas), nil
+}
+
+// statefulSetStatus returns the Status of a StatefulSet
+func statefulSetStatus(u map[string]interface{}) (Status, string, error) {
+	replicas := getInt(u, 1, "spec", "replicas")
+	ready := getInt(u, 0, "status", "readyReplicas")
+	if ready < replicas {
+		return InProgressStatus, fmt.Sprintf("%d/%d replicas ready", ready, replicas), nil
+	}
+	strategy, _, _ := unstructured.NestedString(u, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		return CurrentStatus, fmt.Sprintf("%d/%d replicas ready", ready, replicas), nil
+	}
+	partition := getInt(u, 0, "spec", "updateStrategy", "rollingUpdate", "partition")
+	updated := getInt(u, 0, "status", "updatedReplicas")
+	if updated < replicas-partition {
+		return InProgressStatus, fmt.Sprintf("%d/%d replicas updated", updated, replicas-partition), nil
+	}
+	currentRevision, _, _ := unstructured.NestedString(u, "status", "currentRevision")
+	updateRevision, _, _ := unstructured.NestedString(u, "status", "updateRevision")
+	if partition == 0 && currentRevision != updateRevision {
+		return InProgressStatus, fmt.Sprintf("waiting for revision %s to roll out", updateRevision), nil
+	}
+	return CurrentStatus, fmt.Sprintf("%d/%d replicas ready", ready, replicas), nil
+}
+
+// daemonSetStatus returns the Status of a DaemonSet
+func daemonSetStatus(u map[string]interface{}) (Status, string, error) {
+	desired := getInt(u, 0, "status", "desiredNumberScheduled")
+	updated := getInt(u, 0, "status", "updatedNumberScheduled")
+	available := getInt(u, 0, "status", "numberAvailable")
+	if updated < desired {
+		return InProgressStatus, fmt.Sprintf("%d/%d pods updated", updated, desired), nil
+	}
+	if available < desired {
+		return InProgressStatus, fmt.Sprintf("%d/%d pods available", available, desired), nil
+	}
+	return CurrentStatus, fmt.Sprintf("%d/%d pods available", available, desired), nil
+}
+
+// jobStatus returns the Status of a Job
+func jobStatus(u map[string]interface{}) (Status, string, error) {
+	if condition, ok := getCondition(u, "Failed"); ok && condition["status"] == "True" {
+		return FailedStatus, fmt.Sprintf("job failed: %v", condition["message"]), nil
+	}
+	if condition, ok := getCondition(u, "Complete"); ok && condition["status"] == "True" {
+		return CurrentStatus, "job completed", nil
+	}
+	succeeded := getInt(u, 0, "status", "succeeded")
+	completions := getInt(u, 1, "spec", "completions")
+	return InProgressStatus, fmt.Sprintf("%d/%d completions succeeded", succeeded, completions), nil
+}
+
+// pvcStatus returns the Status of a PersistentVolumeClaim
+func pvcStatus(u map[string]interface{}) (Status, string, error) {
+	phase, _, _ := unstructured.NestedString(u, "status", "phase")
+	switch phase {
+	case "Bound":
+		return CurrentStatus, "claim is bound", nil
+	case "Lost":
+		return FailedStatus, "claim has lost its underlying volume", nil
+	}
+	return InProgressStatus, fmt.Sprintf("claim is %s", phase), nil
+}
+
+// genericStatus returns the Status of an object based on the standard Stalled, Reconciling and Ready conditions
+func genericStatus(u map[string]interface{}) (Status, string, error) {
+	if condition, ok := getCondition(u, "Stalled"); ok && condition["status"] == "True" {
+		return FailedStatus, fmt.Sprintf("%v", condition["message"]), nil
+	}
+	if condition, ok := getCondition(u, "Reconciling"); ok && condition["status"] == "True" {
+		return InProgressStatus, fmt.Sprintf("%v", condition["message"]), nil
+	}
+	if condition, ok := getCondition(u, "Ready"); ok && condition["status"] == "False" {
+		return InProgressStatus, fmt.Sprintf("%v", condition["message"]), nil
+	}
+	return CurrentStatus, "", nil
+}
+
+// getCondition returns the condition of the provided type from an object's status
+func getCondition(u map[string]interface{}, conditionType string) (map[string]interface{}, bool) {
+	conditions, _, _ := unstructured.NestedSlice(u, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			return condition, true
+		}
+	}
+	return nil, false
+}
+
+// getInt returns the integer at the provided path of an object or the provided default if it is not set
+func getInt(u map[string]interface{}, def int64, fields ...string) int64 {
+	val, found, err := unstructured.NestedFieldNoCopy(u, fields...)
+	if !found || err != nil {
+		return def
+	}
+	switch v := val.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return def
+}
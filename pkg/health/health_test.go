@@ -0,0 +1,278 @@
+package health
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	deploymentGK  = schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	statefulSetGK = schema.GroupKind{Group: "apps", Kind: "StatefulSet"}
+	daemonSetGK   = schema.GroupKind{Group: "apps", Kind: "DaemonSet"}
+	jobGK         = schema.GroupKind{Group: "batch", Kind: "Job"}
+	pvcGK         = schema.GroupKind{Kind: "PersistentVolumeClaim"}
+	customGK      = schema.GroupKind{Group: "example.com", Kind: "Foo"}
+)
+
+// newObject returns an object with the provided spec and status
+func newObject(spec, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "foo", "generation": int64(1)},
+	}}
+	if spec != nil {
+		obj.Object["spec"] = spec
+	}
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	return obj
+}
+
+// conditions returns a status with a single condition
+func conditions(conditionType, status, reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": conditionType, "status": status, "reason": reason, "message": reason},
+		},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	testCases := []struct {
+		name     string
+		gk       schema.GroupKind
+		obj      *unstructured.Unstructured
+		expected Status
+	}{
+		{
+			name: "generation not observed yet",
+			gk:   deploymentGK,
+			obj: newObject(map[string]interface{}{"replicas": int64(1)}, map[string]interface{}{
+				"observedGeneration": int64(0),
+				"replicas":           int64(1),
+				"updatedReplicas":    int64(1),
+				"availableReplicas":  int64(1),
+				"readyReplicas":      int64(1),
+			}),
+			expected: InProgressStatus,
+		},
+		{
+			name: "deployment available",
+			gk:   deploymentGK,
+			obj: newObject(map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"observedGeneration": int64(1),
+				"replicas":           int64(2),
+				"updatedReplicas":    int64(2),
+				"availableReplicas":  int64(2),
+				"readyReplicas":      int64(2),
+			}),
+			expected: CurrentStatus,
+		},
+		{
+			name: "deployment rolling out",
+			gk:   deploymentGK,
+			obj: newObject(map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"replicas":          int64(3),
+				"updatedReplicas":   int64(2),
+				"availableReplicas": int64(2),
+				"readyReplicas":     int64(2),
+			}),
+			expected: InProgressStatus,
+		},
+		{
+			name: "deployment with default replicas unavailable",
+			gk:   deploymentGK,
+			obj: newObject(nil, map[string]interface{}{
+				"replicas":        int64(1),
+				"updatedReplicas": int64(1),
+			}),
+			expected: InProgressStatus,
+		},
+		{
+			name:     "deployment past its progress deadline",
+			gk:       deploymentGK,
+			obj:      newObject(map[string]interface{}{"replicas": int64(1)}, conditions("Progressing", "False", "ProgressDeadlineExceeded")),
+			expected: FailedStatus,
+		},
+		{
+			name: "statefulset rolled out",
+			gk:   statefulSetGK,
+			obj: newObject(map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"readyReplicas":   int64(2),
+				"updatedReplicas": int64(2),
+				"currentRevision": "foo-1",
+				"updateRevision":  "foo-1",
+			}),
+			expected: CurrentStatus,
+		},
+		{
+			name: "statefulset with a partitioned rollout",
+			gk:   statefulSetGK,
+			obj: newObject(map[string]interface{}{
+				"replicas": int64(3),
+				"updateStrategy": map[string]interface{}{
+					"type":          "RollingUpdate",
+					"rollingUpdate": map[string]interface{}{"partition": int64(2)},
+				},
+			}, map[string]interface{}{
+				"readyReplicas":   int64(3),
+				"updatedReplicas": int64(1),
+				"currentRevision": "foo-1",
+				"updateRevision":  "foo-2",
+			}),
+			expected: CurrentStatus,
+		},
+		{
+			name: "statefulset with an on delete update strategy",
+			gk:   statefulSetGK,
+			obj: newObject(map[string]interface{}{
+				"replicas":       int64(1),
+				"updateStrategy": map[string]interface{}{"type": "OnDelete"},
+			}, map[string]interface{}{
+				"readyReplicas":   int64(1),
+				"currentRevision": "foo-1",
+				"updateRevision":  "foo-2",
+			}),
+			expected: CurrentStatus,
+		},
+		{
+			name: "statefulset rolling out a revision",
+			gk:   statefulSetGK,
+			obj: newObject(map[string]interface{}{"replicas": int64(1)}, map[string]interface{}{
+				"readyReplicas":   int64(1),
+				"updatedReplicas": int64(1),
+				"currentRevision": "foo-1",
+				"updateRevision":  "foo-2",
+			}),
+			expected: InProgressStatus,
+		},
+		{
+			// StatefulSets have no terminal failure state, so replicas that never become ready are still in progress
+			name:     "statefulset not ready",
+			gk:       statefulSetGK,
+			obj:      newObject(map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"readyReplicas": int64(1)}),
+			expected: InProgressStatus,
+		},
+		{
+			name: "daemonset available",
+			gk:   daemonSetGK,
+			obj: newObject(nil, map[string]interface{}{
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(3),
+				"numberAvailable":        int64(3),
+			}),
+			expected: CurrentStatus,
+		},
+		{
+			name: "daemonset rolling out",
+			gk:   daemonSetGK,
+			obj: newObject(nil, map[string]interface{}{
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(2),
+				"numberAvailable":        int64(3),
+			}),
+			expected: InProgressStatus,
+		},
+		{
+			// DaemonSets have no terminal failure state, so pods that never become available are still in progress
+			name: "daemonset unavailable",
+			gk:   daemonSetGK,
+			obj: newObject(nil, map[string]interface{}{
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(3),
+				"numberAvailable":        int64(0),
+			}),
+			expected: InProgressStatus,
+		},
+		{
+			name:     "job completed",
+			gk:       jobGK,
+			obj:      newObject(nil, conditions("Complete", "True", "Completed")),
+			expected: CurrentStatus,
+		},
+		{
+			name:     "job running",
+			gk:       jobGK,
+			obj:      newObject(map[string]interface{}{"completions": int64(2)}, map[string]interface{}{"succeeded": int64(1)}),
+			expected: InProgressStatus,
+		},
+		{
+			name:     "job failed",
+			gk:       jobGK,
+			obj:      newObject(nil, conditions("Failed", "True", "BackoffLimitExceeded")),
+			expected: FailedStatus,
+		},
+		{
+			name:     "pvc bound",
+			gk:       pvcGK,
+			obj:      newObject(nil, map[string]interface{}{"phase": "Bound"}),
+			expected: CurrentStatus,
+		},
+		{
+			name:     "pvc pending",
+			gk:       pvcGK,
+			obj:      newObject(nil, map[string]interface{}{"phase": "Pending"}),
+			expected: InProgressStatus,
+		},
+		{
+			name:     "pvc lost",
+			gk:       pvcGK,
+			obj:      newObject(nil, map[string]interface{}{"phase": "Lost"}),
+			expected: FailedStatus,
+		},
+		{
+			name:     "object without conditions",
+			gk:       customGK,
+			obj:      newObject(nil, nil),
+			expected: CurrentStatus,
+		},
+		{
+			name:     "object ready",
+			gk:       customGK,
+			obj:      newObject(nil, conditions("Ready", "True", "Ready")),
+			expected: CurrentStatus,
+		},
+		{
+			name:     "object not ready",
+			gk:       customGK,
+			obj:      newObject(nil, conditions("Ready", "False", "Waiting")),
+			expected: InProgressStatus,
+		},
+		{
+			name:     "object reconciling",
+			gk:       customGK,
+			obj:      newObject(nil, conditions("Reconciling", "True", "Updating")),
+			expected: InProgressStatus,
+		},
+		{
+			name:     "object stalled",
+			gk:       customGK,
+			obj:      newObject(nil, conditions("Stalled", "True", "InvalidSpec")),
+			expected: FailedStatus,
+		},
+		{
+			name: "object with an unobserved generation",
+			gk:   customGK,
+			obj: newObject(nil, map[string]interface{}{
+				"observedGeneration": int64(0),
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Stalled", "status": "True"},
+				},
+			}),
+			expected: InProgressStatus,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, message, err := Compute(tc.gk, tc.obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tc.expected {
+				t.Errorf("expected status %s, got %s (%s)", tc.expected, status, message)
+			}
+		})
+	}
+}
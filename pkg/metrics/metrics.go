@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ContestedObjects is the number of objects of a Helm release that are contested
+	ContestedObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helm_locker_contested_objects",
+		Help: "Number of objects of a Helm release that were reverted too often and are no longer being reverted",
+	}, []string{"release_namespace", "release_name"})
+
+	// ContestedObjectsTotal is the number of times objects of a Helm release became contested
+	ContestedObjectsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "helm_locker_contested_objects_total",
+		Help: "Number of times objects of a Helm release were reverted too often and stopped being reverted",
+	}, []string{"release_namespace", "release_name", "kind"})
+
+	// UnenforceableObjects is the number of objects of a Helm release that are unenforceable
+	UnenforceableObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "helm_locker_unenforceable_objects",
+		Help: "Number of objects of a Helm release that did not match the release after being reverted and are no longer being reverted",
+	}, []string{"release_namespace", "release_name"})
+)
+
+func init() {
+	prometheus.MustRegister(ContestedObjects, ContestedObjectsTotal, UnenforceableObjects)
+}
+
+// Serve serves metrics on the provided address until the context is done
+func Serve(ctx context.Context, address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	logrus.Infof("serving metrics on %s", address)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Errorf("unable to serve metrics: %s", err)
+	}
+}
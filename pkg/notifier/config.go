@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigKey is the key in the notifier ConfigMap that contains the Config
+	ConfigKey = "config.yaml"
+
+	// WebhookSinkType is a sink that receives the Notification as a generic JSON payload
+	WebhookSinkType SinkType = "webhook"
+
+	// CloudEventsSinkType is a sink that receives the Notification as a structured CloudEvent over HTTP
+	CloudEventsSinkType SinkType = "cloudevents"
+
+	// SlackSinkType is a sink that receives the Notification as a Slack-compatible incoming webhook payload
+	SlackSinkType SinkType = "slack"
+)
+
+// SinkType is the type of a sink, which determines the format of the payload it receives
+type SinkType string
+
+// Options are the options used to configure the Notifier
+type Options struct {
+	// ConfigMapName is the name of a ConfigMap in the system namespace that contains a Config under ConfigKey
+	ConfigMapName string
+	// Sinks are sinks that are always configured, regardless of the contents of the ConfigMap
+	Sinks []SinkConfig
+}
+
+// Config is the configuration of the sinks that a Notifier dispatches Notifications to
+type Config struct {
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+}
+
+// ParseConfig parses a Config from its YAML or JSON representation
+func ParseConfig(data string) (Config, error) {
+	var config Config
+	if err := yaml.UnmarshalStrict([]byte(data), &config); err != nil {
+		return config, fmt.Errorf("unable to parse notifier config: %s", err)
+	}
+	for _, sink := range config.Sinks {
+		if err := sink.withDefaults().Validate(); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// SinkConfig configures a single sink
+type SinkConfig struct {
+	// Name uniquely identifies the sink in logs
+	// Since URLs often embed credentials (e.g. Slack incoming webhooks), the URL of a sink is never logged
+	Name string `json:"name"`
+	// Type is the type of the sink
+	Type SinkType `json:"type"`
+	// URL is the HTTP(S) endpoint that Notifications are sent to
+	URL string `json:"url"`
+	// Headers are additional HTTP headers sent with every request, e.g. for authentication
+	Headers map[string]string `json:"headers,omitempty"`
+	// Releases filters which releases this sink is notified about
+	Releases ReleaseFilter `json:"releases,omitempty"`
+
+	// Timeout is the timeout of a single request to the sink (default: 10s)
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// MaxRetries is the number of times a failed request is retried before the Notification is dropped (default: 5)
+	// A MaxRetries of 0 disables retries
+	MaxRetries *int `json:"maxRetries,omitempty"`
+	// InitialBackoff is the time to wait before the first retry, which doubles on every subsequent retry (default: 1s)
+	InitialBackoff metav1.Duration `json:"initialBackoff,omitempty"`
+	// DedupWindow is the window in which identical Notifications are only sent once (default: 5m)
+	DedupWindow metav1.Duration `json:"dedupWindow,omitempty"`
+	// RateLimitPerMinute is the maximum number of Notifications sent to this sink per minute (default: 30)
+	RateLimitPerMinute int `json:"rateLimitPerMinute,omitempty"`
+}
+
+// NewSinkConfig returns a SinkConfig for a sink with the given name, type and URL with default settings
+func NewSinkConfig(name string, sinkType SinkType, sinkURL string) SinkConfig {
+	return SinkConfig{
+		Name: name,
+		Type: sinkType,
+		URL:  sinkURL,
+	}
+}
+
+// Validate returns an error if the SinkConfig is invalid
+func (c SinkConfig) Validate() error {
+	if len(c.Name) == 0 {
+		return fmt.Errorf("notifier sink must have a name")
+	}
+	switch c.Type {
+	case WebhookSinkType, CloudEventsSinkType, SlackSinkType:
+	default:
+		return fmt.Errorf("notifier sink %s has unknown type %q", c.Name, c.Type)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		// the error returned contains the url, so it is not included
+		return fmt.Errorf("notifier sink %s has an invalid url", c.Name)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("notifier sink %s must have an http or https url", c.Name)
+	}
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		return fmt.Errorf("notifier sink %s cannot have a negative maxRetries", c.Name)
+	}
+	return nil
+}
+
+// withDefaults returns a copy of the SinkConfig with defaults applied to unset fields
+func (c SinkConfig) withDefaults() SinkConfig {
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = 10 * time.Second
+	}
+	if c.MaxRetries == nil {
+		maxRetries := 5
+		c.MaxRetries = &maxRetries
+	}
+	if c.InitialBackoff.Duration == 0 {
+		c.InitialBackoff.Duration = time.Second
+	}
+	if c.DedupWindow.Duration == 0 {
+		c.DedupWindow.Duration = 5 * time.Minute
+	}
+	if c.RateLimitPerMinute <= 0 {
+		c.RateLimitPerMinute = 30
+	}
+	return c
+}
@@ -0,0 +1,244 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Notification describes a set of objects locked by a Helm release that drifted and were reverted
+type Notification struct {
+	// ReleaseNamespace is the namespace of the Helm release whose objects were reverted
+	ReleaseNamespace string `json:"releaseNamespace"`
+	// ReleaseName is the name of the Helm release whose objects were reverted
+	ReleaseName string `json:"releaseName"`
+	// Objects are the objects that were reverted
+	Objects []Object `json:"objects"`
+	// Time is when the objects were reverted
+	Time time.Time `json:"time"`
+}
+
+// Object identifies a single object that was reverted
+type Object struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Source is the template that rendered the object (e.g. chart/templates/configmap.yaml), if known
+	Source string `json:"source,omitempty"`
+}
+
+// String returns a human-readable representation of the Object
+func (o Object) String() string {
+	if len(o.Namespace) == 0 {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// Release returns the namespace/name of the Helm release tied to this Notification
+func (n Notification) Release() string {
+	return fmt.Sprintf("%s/%s", n.ReleaseNamespace, n.ReleaseName)
+}
+
+// Summary returns a human-readable summary of the Notification
+func (n Notification) Summary() string {
+	objects := make([]string, len(n.Objects))
+	for i, obj := range n.Objects {
+		objects[i] = obj.String()
+	}
+	return fmt.Sprintf("helm-locker reverted drift on %d object(s) locked by Helm release %s: %s", len(n.Objects), n.Release(), strings.Join(objects, ", "))
+}
+
+// digest returns a key that uniquely identifies the content of the Notification, ignoring when it was sent
+func (n Notification) digest() string {
+	objects := make([]string, len(n.Objects))
+	for i, obj := range n.Objects {
+		objects[i] = fmt.Sprintf("%s/%s", obj.APIVersion, obj.String())
+	}
+	sort.Strings(objects)
+	return fmt.Sprintf("%s:%s", n.Release(), strings.Join(objects, ","))
+}
+
+// Notifier sends Notifications to a set of configured sinks
+type Notifier struct {
+	// sinks are the sinks that Notifications are dispatched to
+	sinks []*sink
+	// sinksLock is a lock on the sinks slice
+	sinksLock sync.RWMutex
+}
+
+// New returns a Notifier that does not send Notifications until it is configured
+func New() *Notifier {
+	return &Notifier{}
+}
+
+// Configure replaces the sinks that this Notifier dispatches Notifications to
+func (n *Notifier) Configure(configs []SinkConfig) error {
+	var sinks []*sink
+	for _, config := range configs {
+		s, err := newSink(config)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, s)
+	}
+	n.sinksLock.Lock()
+	defer n.sinksLock.Unlock()
+	n.sinks = sinks
+	return nil
+}
+
+// Notify dispatches a Notification to every sink whose release filter matches it and blocks until all sinks have
+// either accepted the Notification, dropped it, or exhausted their retries
+func (n *Notifier) Notify(ctx context.Context, notification Notification) {
+	n.sinksLock.RLock()
+	sinks := n.sinks
+	n.sinksLock.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, s := range sinks {
+		if !s.config.Releases.Matches(notification.Release()) {
+			continue
+		}
+		wg.Add(1)
+		go func(s *sink) {
+			defer wg.Done()
+			if err := s.notify(ctx, notification); err != nil {
+				logrus.Errorf("unable to send notification for release %s to sink %s: %s", notification.Release(), s.config.Name, err)
+			}
+		}(s)
+	}
+	wg.Wait()
+}
+
+// sink wraps a Sender with the retries, release filters, deduplication and rate limiting configured for it
+type sink struct {
+	config SinkConfig
+	sender Sender
+
+	// limiter limits the rate at which Notifications are sent to this sink
+	limiter *rate.Limiter
+
+	// sentByDigest keeps track of when a Notification with a given digest was last sent to this sink
+	sentByDigest map[string]time.Time
+	// sentLock is a lock on the sentByDigest map
+	sentLock sync.Mutex
+}
+
+func newSink(config SinkConfig) (*sink, error) {
+	config = config.withDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	sender, err := newSender(config)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{
+		config:       config,
+		sender:       sender,
+		limiter:      rate.NewLimiter(rate.Limit(float64(config.RateLimitPerMinute)/60), config.RateLimitPerMinute),
+		sentByDigest: make(map[string]time.Time),
+	}, nil
+}
+
+// notify sends a Notification to the sink unless it is a duplicate or the sink is rate limited
+func (s *sink) notify(ctx context.Context, notification Notification) error {
+	if s.isDuplicate(notification) {
+		logrus.Debugf("dropping duplicate notification for release %s to sink %s", notification.Release(), s.config.Name)
+		return nil
+	}
+	if !s.limiter.Allow() {
+		return fmt.Errorf("rate limit of %d notifications per minute exceeded, dropping notification", s.config.RateLimitPerMinute)
+	}
+
+	backoff := wait.Backoff{
+		Duration: s.config.InitialBackoff.Duration,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    *s.config.MaxRetries + 1,
+	}
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		sendCtx, cancel := context.WithTimeout(ctx, s.config.Timeout.Duration)
+		defer cancel()
+		lastErr = s.sender.Send(sendCtx, notification)
+		if lastErr == nil {
+			return true, nil
+		}
+		var permanent *PermanentError
+		if errors.As(lastErr, &permanent) {
+			return false, lastErr
+		}
+		logrus.Debugf("retrying notification for release %s to sink %s: %s", notification.Release(), s.config.Name, lastErr)
+		return false, nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) && lastErr != nil {
+			return fmt.Errorf("giving up after %d attempts: %s", backoff.Steps, lastErr)
+		}
+		return err
+	}
+	// only Notifications that were delivered are deduplicated, so that a dropped Notification is sent again on the next
+	// revert of the same objects
+	s.recordSent(notification)
+	return nil
+}
+
+// isDuplicate returns whether the same Notification was already sent to this sink within its dedup window
+func (s *sink) isDuplicate(notification Notification) bool {
+	s.sentLock.Lock()
+	defer s.sentLock.Unlock()
+	now := time.Now()
+	for digest, sent := range s.sentByDigest {
+		if now.Sub(sent) >= s.config.DedupWindow.Duration {
+			delete(s.sentByDigest, digest)
+		}
+	}
+	_, ok := s.sentByDigest[notification.digest()]
+	return ok
+}
+
+// recordSent records that a Notification was sent to this sink
+func (s *sink) recordSent(notification Notification) {
+	s.sentLock.Lock()
+	defer s.sentLock.Unlock()
+	s.sentByDigest[notification.digest()] = time.Now()
+}
+
+// ReleaseFilter selects the releases whose Notifications are sent to a sink
+type ReleaseFilter struct {
+	// Include is a list of namespace/name glob patterns of releases to send Notifications for
+	// If empty, all releases are included
+	Include []string `json:"include,omitempty"`
+	// Exclude is a list of namespace/name glob patterns of releases to not send Notifications for
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Matches returns whether a release identified by namespace/name passes the filter
+func (f ReleaseFilter) Matches(release string) bool {
+	for _, pattern := range f.Exclude {
+		if ok, _ := path.Match(pattern, release); ok {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if ok, _ := path.Match(pattern, release); ok {
+			return true
+		}
+	}
+	return false
+}
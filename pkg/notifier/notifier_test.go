@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recorder is a local HTTP server that records the requests it receives
+type recorder struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	requests []recordedRequest
+	statuses []int
+}
+
+type recordedRequest struct {
+	contentType string
+	body        map[string]interface{}
+}
+
+// newRecorder returns a recorder that responds with the provided statuses in order, then with 200
+func newRecorder(t *testing.T, statuses ...int) *recorder {
+	r := &recorder{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("unable to decode request body: %s", err)
+		}
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.requests = append(r.requests, recordedRequest{contentType: req.Header.Get("Content-Type"), body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *recorder) received() []recordedRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]recordedRequest{}, r.requests...)
+}
+
+func newTestNotification() Notification {
+	return Notification{
+		ReleaseNamespace: "foo",
+		ReleaseName:      "foochart",
+		Objects: []Object{
+			{APIVersion: "v1", Kind: "ConfigMap", Namespace: "foo", Name: "foochart-config"},
+		},
+		Time: time.Now(),
+	}
+}
+
+func newTestSinkConfig(sinkType SinkType, url string) SinkConfig {
+	config := NewSinkConfig(string(sinkType), sinkType, url)
+	config.InitialBackoff = metav1.Duration{Duration: time.Millisecond}
+	return config
+}
+
+func TestNotifySinkTypes(t *testing.T) {
+	webhook := newRecorder(t)
+	cloudEvents := newRecorder(t)
+	slack := newRecorder(t)
+
+	n := New()
+	if err := n.Configure([]SinkConfig{
+		newTestSinkConfig(WebhookSinkType, webhook.URL),
+		newTestSinkConfig(CloudEventsSinkType, cloudEvents.URL),
+		newTestSinkConfig(SlackSinkType, slack.URL),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(context.Background(), newTestNotification())
+
+	if reqs := webhook.received(); len(reqs) != 1 || reqs[0].body["releaseName"] != "foochart" {
+		t.Errorf("expected webhook sink to receive the notification, got %v", reqs)
+	}
+	if reqs := cloudEvents.received(); len(reqs) != 1 || reqs[0].contentType != "application/cloudevents+json" || reqs[0].body["type"] != CloudEventType || reqs[0].body["subject"] != "foo/foochart" {
+		t.Errorf("expected cloudevents sink to receive a CloudEvent, got %v", reqs)
+	}
+	if reqs := slack.received(); len(reqs) != 1 || reqs[0].body["text"] == nil {
+		t.Errorf("expected slack sink to receive a message, got %v", reqs)
+	}
+}
+
+func TestNotifyRetries(t *testing.T) {
+	transient := newRecorder(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	permanent := newRecorder(t, http.StatusBadRequest)
+
+	n := New()
+	if err := n.Configure([]SinkConfig{
+		newTestSinkConfig(WebhookSinkType, transient.URL),
+		newTestSinkConfig(WebhookSinkType, permanent.URL),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(context.Background(), newTestNotification())
+
+	if reqs := transient.received(); len(reqs) != 3 {
+		t.Errorf("expected transient failures to be retried until success, got %d requests", len(reqs))
+	}
+	if reqs := permanent.received(); len(reqs) != 1 {
+		t.Errorf("expected permanent failures to not be retried, got %d requests", len(reqs))
+	}
+}
+
+func TestNotifyRetriesDisabled(t *testing.T) {
+	transient := newRecorder(t, http.StatusServiceUnavailable)
+
+	config, err := ParseConfig(fmt.Sprintf("sinks:\n- name: ops\n  type: webhook\n  url: %s\n  maxRetries: 0\n", transient.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New()
+	if err := n.Configure(config.Sinks); err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(context.Background(), newTestNotification())
+
+	if reqs := transient.received(); len(reqs) != 1 {
+		t.Errorf("expected failures to not be retried with a maxRetries of 0, got %d requests", len(reqs))
+	}
+	if _, err := ParseConfig("sinks:\n- name: ops\n  type: webhook\n  url: https://example.com\n  maxRetries: -1\n"); err == nil {
+		t.Errorf("expected negative maxRetries to be rejected")
+	}
+}
+
+func TestNotifyDedupAndFilters(t *testing.T) {
+	included := newRecorder(t)
+	excluded := newRecorder(t)
+
+	includedConfig := newTestSinkConfig(WebhookSinkType, included.URL)
+	includedConfig.Releases.Include = []string{"foo/*"}
+	excludedConfig := newTestSinkConfig(WebhookSinkType, excluded.URL)
+	excludedConfig.Releases.Exclude = []string{"*/foochart"}
+
+	n := New()
+	if err := n.Configure([]SinkConfig{includedConfig, excludedConfig}); err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(context.Background(), newTestNotification())
+	n.Notify(context.Background(), newTestNotification())
+
+	if reqs := included.received(); len(reqs) != 1 {
+		t.Errorf("expected duplicate notifications to be sent once, got %d requests", len(reqs))
+	}
+	if reqs := excluded.received(); len(reqs) != 0 {
+		t.Errorf("expected excluded release to not be sent, got %d requests", len(reqs))
+	}
+}
+
+func TestNotifyDedupOnlyDelivered(t *testing.T) {
+	rejecting := newRecorder(t, http.StatusBadRequest)
+
+	n := New()
+	if err := n.Configure([]SinkConfig{newTestSinkConfig(WebhookSinkType, rejecting.URL)}); err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(context.Background(), newTestNotification())
+	n.Notify(context.Background(), newTestNotification())
+	n.Notify(context.Background(), newTestNotification())
+
+	if reqs := rejecting.received(); len(reqs) != 2 {
+		t.Errorf("expected notification to be sent again after it was rejected and deduplicated once delivered, got %d requests", len(reqs))
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(`
+sinks:
+- name: ops
+  type: cloudevents
+  url: https://example.com/events
+  dedupWindow: 1m
+  releases:
+    include: ["cattle-monitoring-system/*"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Sinks) != 1 || config.Sinks[0].DedupWindow.Duration != time.Minute {
+		t.Errorf("unexpected config %v", config)
+	}
+	if _, err := ParseConfig("sinks:\n- name: ops\n  type: email\n  url: https://example.com\n"); err == nil {
+		t.Errorf("expected unknown sink type to be rejected")
+	}
+}
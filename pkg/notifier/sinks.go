@@ -0,0 +1,146 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CloudEventType is the type of the CloudEvents sent by the cloudevents sink
+	CloudEventType = "io.cattle.helm.locker.drift.reverted"
+
+	// CloudEventSource is the source of the CloudEvents sent by the cloudevents sink
+	CloudEventSource = "helm-locker"
+)
+
+// Sender sends a Notification to a single destination
+type Sender interface {
+	Send(ctx context.Context, notification Notification) error
+}
+
+// PermanentError is returned by a Sender when retrying the request would not succeed
+type PermanentError struct {
+	StatusCode int
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("sink rejected notification with status %d", e.StatusCode)
+}
+
+// newSender returns the Sender for the type of sink configured
+func newSender(config SinkConfig) (Sender, error) {
+	poster := &httpPoster{
+		client:  &http.Client{},
+		url:     config.URL,
+		headers: config.Headers,
+	}
+	switch config.Type {
+	case WebhookSinkType:
+		return &webhookSender{poster}, nil
+	case CloudEventsSinkType:
+		return &cloudEventsSender{poster}, nil
+	case SlackSinkType:
+		return &slackSender{poster}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", config.Type)
+}
+
+// webhookSender posts the Notification as-is as a JSON payload
+type webhookSender struct {
+	*httpPoster
+}
+
+func (s *webhookSender) Send(ctx context.Context, notification Notification) error {
+	return s.post(ctx, "application/json", notification)
+}
+
+// cloudEventsSender posts the Notification as the data of a CloudEvent in structured content mode
+type cloudEventsSender struct {
+	*httpPoster
+}
+
+// cloudEvent is a CloudEvents v1.0 event in its JSON format
+type cloudEvent struct {
+	SpecVersion     string       `json:"specversion"`
+	ID              string       `json:"id"`
+	Source          string       `json:"source"`
+	Type            string       `json:"type"`
+	Subject         string       `json:"subject"`
+	Time            string       `json:"time"`
+	DataContentType string       `json:"datacontenttype"`
+	Data            Notification `json:"data"`
+}
+
+func (s *cloudEventsSender) Send(ctx context.Context, notification Notification) error {
+	return s.post(ctx, "application/cloudevents+json", cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              uuid.New().String(),
+		Source:          CloudEventSource,
+		Type:            CloudEventType,
+		Subject:         notification.Release(),
+		Time:            notification.Time.UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Data:            notification,
+	})
+}
+
+// slackSender posts a summary of the Notification as a Slack-compatible incoming webhook payload
+type slackSender struct {
+	*httpPoster
+}
+
+func (s *slackSender) Send(ctx context.Context, notification Notification) error {
+	return s.post(ctx, "application/json", map[string]string{
+		"text": notification.Summary(),
+	})
+}
+
+// httpPoster posts JSON payloads to a URL
+type httpPoster struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+// post sends the payload encoded as JSON and returns an error if the request did not succeed
+func (p *httpPoster) post(ctx context.Context, contentType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// the url of the sink may contain credentials, so it is stripped from the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s request to sink failed: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &PermanentError{StatusCode: resp.StatusCode}
+	}
+	return fmt.Errorf("sink responded with status %d", resp.StatusCode)
+}
@@ -3,6 +3,7 @@ package objectset
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -32,17 +33,64 @@ type Register interface {
 	// if os or locked are not provided, the currently persisted values will be used
 	Set(key relatedresource.Key, os *objectset.ObjectSet, locked *bool)
 
-	// Delete allows you to delete an objectset associated with a specific key
-	Delete(key relatedresource.Key, purge bool)
+	// Delete allows you to delete an objectset associated with a specific key without deleting the underlying resources
+	Delete(key relatedresource.Key)
+
+	// Purge allows you to delete an objectset associated with a specific key and the underlying resources of the provided GVKs
+	Purge(key relatedresource.Key, gvks []schema.GroupVersionKind)
+
+	// GVKs returns the GVKs of the objects in the objectset currently set for a specific key, if any
+	GVKs(key relatedresource.Key) []schema.GroupVersionKind
+
+	// Configure allows you to override how an objectset associated with a specific key is locked
+	Configure(key relatedresource.Key, config Config)
+
+	// SetSources allows you to record the templates that rendered the objects of an objectset associated with a specific key
+	SetSources(key relatedresource.Key, sources Sources)
+
+	// SetReleased allows you to record the objects that are deliberately not tracked by an objectset associated with a
+	// specific key; any of them that were applied for the objectset are left in the cluster rather than pruned
+	SetReleased(key relatedresource.Key, released *objectset.ObjectSet)
 }
 
 // Locker can lock or unlock object sets tied to a specific key
 type Locker interface {
 	// Lock allows you to lock an objectset associated with a specific key
-	Lock(key relatedresource.Key)
+	// It returns the conflicts with other objectsets that track the same objects that were found on locking it
+	Lock(key relatedresource.Key) []Conflict
 
 	// Unlock allows you to unlock an objectset associated with a specific key
 	Unlock(key relatedresource.Key)
+
+	// Conflicts returns the conflicts that prevent an objectset associated with a specific key from locking objects
+	Conflicts(key relatedresource.Key) []Conflict
+}
+
+// triggeringLocker is a Locker that also keeps track of the objects whose changes enqueued each ObjectSet
+type triggeringLocker interface {
+	Locker
+
+	// takeTriggers returns the objects whose changes enqueued an objectset associated with a specific key since the last
+	// time they were taken
+	takeTriggers(key relatedresource.Key) objectKeysByGVK
+
+	// suspend stops resolving changes to the objects locked by an objectset associated with a specific key to it
+	// without giving up its claim on them, e.g. while it is being applied
+	suspend(key relatedresource.Key)
+
+	// resume resumes resolving changes to the objects locked by an objectset associated with a specific key to it
+	resume(key relatedresource.Key)
+}
+
+// objectKeysByGVK is a set of objects identified by their GVK and key
+type objectKeysByGVK map[schema.GroupVersionKind]map[objectset.ObjectKey]bool
+
+// add adds an object to the set
+func (o objectKeysByGVK) add(gvk schema.GroupVersionKind, key objectset.ObjectKey) {
+	if _, ok := o[gvk]; !ok {
+		o[gvk] = make(map[objectset.ObjectKey]bool)
+	}
+	o[gvk][key] = true
 }
 
 // newLockableObjectSetRegisterAndCache returns:
@@ -50,10 +98,15 @@ type Locker interface {
 // 2) a cache.SharedIndexInformer that listens to events on objectSetStates that are created from interacting with the provided register
 //
 // Note: This function is intentionally internal since the cache.SharedIndexInformer responds to an internal runtime.Object type (objectSetState)
-func newLockableObjectSetRegisterAndCache(scf controller.SharedControllerFactory, triggerOnDelete func(string, bool)) (LockableRegister, cache.SharedIndexInformer) {
+func newLockableObjectSetRegisterAndCache(scf controller.SharedControllerFactory, conflictPolicy ConflictPolicy, triggerOnDelete func(string, []schema.GroupVersionKind)) (*lockableObjectSetRegisterAndCache, cache.SharedIndexInformer) {
 	c := lockableObjectSetRegisterAndCache{
 		stateByKey:            make(map[relatedresource.Key]*objectSetState),
 		keyByResourceKeyByGVK: make(map[schema.GroupVersionKind]map[relatedresource.Key]relatedresource.Key),
+		conflictsByContender:  make(map[relatedresource.Key][]Conflict),
+		triggersByKey:         make(map[relatedresource.Key]objectKeysByGVK),
+		suspendedKeys:         make(map[relatedresource.Key]bool),
+
+		conflictPolicy: conflictPolicy,
 
 		stateChanges: make(chan watch.Event, 50),
 
@@ -95,13 +148,28 @@ type lockableObjectSetRegisterAndCache struct {
 	// keyByResourceKeyByGVK is a map that keeps track of which resources are tied to a particular ObjectSet
 	// This is used to make resolving the objectset on seeing changes to underlying resources more efficient
 	keyByResourceKeyByGVK map[schema.GroupVersionKind]map[relatedresource.Key]relatedresource.Key
-	// keyMapLock is a lock on the keyByResourceKeyByGVK map
+	// conflictsByContender is a map that keeps track of the objects that each ObjectSet could not lock
+	// since they are locked by another ObjectSet
+	conflictsByContender map[relatedresource.Key][]Conflict
+	// suspendedKeys is a set of ObjectSets whose locked resources are not resolved to them, e.g. while they are being applied
+	// Unlike unlocking, suspending an ObjectSet keeps its claim on the resources it locks
+	suspendedKeys map[relatedresource.Key]bool
+	// keyMapLock is a lock on the keyByResourceKeyByGVK, conflictsByContender, and suspendedKeys maps
 	keyMapLock sync.RWMutex
 
+	// triggersByKey is a map that keeps track of the objects whose changes enqueued each ObjectSet since it was last applied
+	// This is used to only look for drift in the objects that changed rather than in every object of the ObjectSet
+	triggersByKey map[relatedresource.Key]objectKeysByGVK
+	// triggersLock is a lock on the triggersByKey map
+	triggersLock sync.Mutex
+
+	// conflictPolicy determines which ObjectSet locks an object tracked by more than one ObjectSet
+	conflictPolicy ConflictPolicy
+
 	// triggerOnDelete allows registering a function that gets called on a delete from the cache
-	// purge indicates whether or not the triggerOnDelete function is expected to purge underlying
-	// resources on deleting an objectSet
-	triggerOnDelete func(key string, purge bool)
+	// purgeGVKs are the GVKs of the underlying resources that the triggerOnDelete function is expected to purge
+	// on deleting an objectSet, if any
+	triggerOnDelete func(key string, purgeGVKs []schema.GroupVersionKind)
 }
 
 // init initializes the register and the cache
@@ -164,24 +232,52 @@ func (c *lockableObjectSetRegisterAndCache) Watch(_ metav1.ListOptions) (watch.I
 // Set allows you to set and lock an objectset associated with a specific key
 func (c *lockableObjectSetRegisterAndCache) Set(key relatedresource.Key, os *objectset.ObjectSet, locked *bool) {
 	logrus.Debugf("set objectset for %s/%s", key.Namespace, key.Name)
-	c.setState(key, os, locked, false)
+	c.setState(key, os, locked, nil, false)
+}
+
+// Configure allows you to override how an objectset associated with a specific key is locked
+func (c *lockableObjectSetRegisterAndCache) Configure(key relatedresource.Key, config Config) {
+	logrus.Debugf("configure objectset for %s/%s", key.Namespace, key.Name)
+	c.setState(key, nil, nil, func(s *objectSetState) {
+		s.config = config
+	}, false)
+}
+
+// SetSources allows you to record the templates that rendered the objects of an objectset associated with a specific key
+func (c *lockableObjectSetRegisterAndCache) SetSources(key relatedresource.Key, sources Sources) {
+	logrus.Debugf("set sources for %s/%s", key.Namespace, key.Name)
+	c.setState(key, nil, nil, func(s *objectSetState) {
+		s.sources = sources
+	}, false)
+}
+
+// SetReleased allows you to record the objects that are deliberately not tracked by an objectset associated with a specific key
+func (c *lockableObjectSetRegisterAndCache) SetReleased(key relatedresource.Key, released *objectset.ObjectSet) {
+	logrus.Debugf("set released objects for %s/%s", key.Namespace, key.Name)
+	c.setState(key, nil, nil, func(s *objectSetState) {
+		s.released = released
+	}, false)
 }
 
 // Lock allows you to lock an objectset associated with a specific key
-func (c *lockableObjectSetRegisterAndCache) Lock(key relatedresource.Key) {
+func (c *lockableObjectSetRegisterAndCache) Lock(key relatedresource.Key) []Conflict {
 	logrus.Debugf("locking %s/%s", key.Namespace, key.Name)
 	s, ok := c.getState(key)
 	if !ok {
 		// nothing to lock
-		return
+		return nil
 	}
 	s.mutateMu.RLock()
 	defer s.mutateMu.RUnlock()
 	if s.ObjectSet == nil {
 		// nothing to lock
-		return
+		return nil
+	}
+	conflicts, err := c.lock(key, s)
+	if err != nil {
+		logrus.Errorf("unable to lock objectset %s/%s: %s", key.Namespace, key.Name, err)
 	}
-	c.lock(key, s.ObjectSet)
+	return conflicts
 }
 
 // Unlock allows you to unlock an objectset associated with a specific key
@@ -190,32 +286,81 @@ func (c *lockableObjectSetRegisterAndCache) Unlock(key relatedresource.Key) {
 	c.unlock(key)
 }
 
-// Delete allows you to delete an objectset associated with a specific key
-func (c *lockableObjectSetRegisterAndCache) Delete(key relatedresource.Key, purge bool) {
+// suspend stops resolving changes to the objects locked by an objectset associated with a specific key to it
+func (c *lockableObjectSetRegisterAndCache) suspend(key relatedresource.Key) {
+	c.keyMapLock.Lock()
+	defer c.keyMapLock.Unlock()
+	c.suspendedKeys[key] = true
+}
+
+// resume resumes resolving changes to the objects locked by an objectset associated with a specific key to it
+func (c *lockableObjectSetRegisterAndCache) resume(key relatedresource.Key) {
+	c.keyMapLock.Lock()
+	defer c.keyMapLock.Unlock()
+	delete(c.suspendedKeys, key)
+}
+
+// Conflicts returns the conflicts that prevent an objectset associated with a specific key from locking objects
+func (c *lockableObjectSetRegisterAndCache) Conflicts(key relatedresource.Key) []Conflict {
+	c.keyMapLock.RLock()
+	defer c.keyMapLock.RUnlock()
+	return append([]Conflict(nil), c.conflictsByContender[key]...)
+}
+
+// Delete allows you to delete an objectset associated with a specific key without deleting the underlying resources
+func (c *lockableObjectSetRegisterAndCache) Delete(key relatedresource.Key) {
 	logrus.Debugf("deleting %s/%s", key.Namespace, key.Name)
 	c.deleteState(key)
-	c.triggerOnDelete(fmt.Sprintf("%s/%s", key.Namespace, key.Name), purge)
+	c.triggerOnDelete(fmt.Sprintf("%s/%s", key.Namespace, key.Name), nil)
+}
+
+// Purge allows you to delete an objectset associated with a specific key and the underlying resources of the provided GVKs
+func (c *lockableObjectSetRegisterAndCache) Purge(key relatedresource.Key, gvks []schema.GroupVersionKind) {
+	logrus.Debugf("purging %s/%s", key.Namespace, key.Name)
+	c.deleteState(key)
+	c.triggerOnDelete(fmt.Sprintf("%s/%s", key.Namespace, key.Name), gvks)
+}
+
+// GVKs returns the GVKs of the objects in the objectset currently set for a specific key, if any
+func (c *lockableObjectSetRegisterAndCache) GVKs(key relatedresource.Key) []schema.GroupVersionKind {
+	s, ok := c.getState(key)
+	if !ok {
+		return nil
+	}
+	s.mutateMu.RLock()
+	defer s.mutateMu.RUnlock()
+	if s.ObjectSet == nil {
+		return nil
+	}
+	return s.ObjectSet.GVKs()
 }
 
 // Enqueue allows you to enqueue an objectset associated with a specific key
 func (c *lockableObjectSetRegisterAndCache) Enqueue(namespace, name string) {
 	key := keyFunc(namespace, name)
-	c.setState(key, nil, nil, true)
+	c.setState(key, nil, nil, nil, true)
 }
 
 // Resolve allows you to resolve an object seen in the cluster to an ObjectSet tracked in this LockableRegister
 // Objects will only be resolved if the LockableRegister has locked this ObjectSet
-func (c *lockableObjectSetRegisterAndCache) Resolve(gvk schema.GroupVersionKind, namespace, name string, _ runtime.Object) ([]relatedresource.Key, error) {
-	resourceKey := keyFunc(namespace, name)
-
-	c.keyMapLock.RLock()
-	defer c.keyMapLock.RUnlock()
-	keyByResourceKey, ok := c.keyByResourceKeyByGVK[gvk]
-	if !ok {
+//
+// Objects that are not tied to a set are resolved to the ObjectSet of the Helm release they claim to belong to,
+// if that ObjectSet is locked and looks for stray objects
+func (c *lockableObjectSetRegisterAndCache) Resolve(gvk schema.GroupVersionKind, namespace, name string, obj runtime.Object) ([]relatedresource.Key, error) {
+	key, ok, watching, suspended := c.resolveTracked(gvk, namespace, name)
+	if !watching {
 		// do nothing since we're not watching this GVK anymore
 		return nil, nil
 	}
-	key, ok := keyByResourceKey[resourceKey]
+	if suspended {
+		// do nothing since the ObjectSet that locks the resource is being applied
+		return nil, nil
+	}
+	if ok {
+		c.addTrigger(key, gvk, objectset.ObjectKey{Namespace: namespace, Name: name})
+	} else {
+		key, ok = c.resolveStray(obj)
+	}
 	if !ok {
 		// do nothing since the resource is not tied to a set
 		return nil, nil
@@ -224,6 +369,53 @@ func (c *lockableObjectSetRegisterAndCache) Resolve(gvk schema.GroupVersionKind,
 	return []relatedresource.Key{key}, nil
 }
 
+// addTrigger records that a change to an object enqueued the ObjectSet associated with a key
+func (c *lockableObjectSetRegisterAndCache) addTrigger(key relatedresource.Key, gvk schema.GroupVersionKind, objKey objectset.ObjectKey) {
+	c.triggersLock.Lock()
+	defer c.triggersLock.Unlock()
+	triggers, ok := c.triggersByKey[key]
+	if !ok {
+		triggers = objectKeysByGVK{}
+		c.triggersByKey[key] = triggers
+	}
+	triggers.add(gvk, objKey)
+}
+
+// takeTriggers returns the objects whose changes enqueued the ObjectSet associated with a key since the last time they were taken
+func (c *lockableObjectSetRegisterAndCache) takeTriggers(key relatedresource.Key) objectKeysByGVK {
+	c.triggersLock.Lock()
+	defer c.triggersLock.Unlock()
+	triggers := c.triggersByKey[key]
+	delete(c.triggersByKey, key)
+	return triggers
+}
+
+// resolveTracked returns the key of the ObjectSet that an object is tied to, if any, whether its GVK is still watched,
+// and whether that ObjectSet is suspended
+func (c *lockableObjectSetRegisterAndCache) resolveTracked(gvk schema.GroupVersionKind, namespace, name string) (relatedresource.Key, bool, bool, bool) {
+	c.keyMapLock.RLock()
+	defer c.keyMapLock.RUnlock()
+	keyByResourceKey, watching := c.keyByResourceKeyByGVK[gvk]
+	if !watching {
+		return relatedresource.Key{}, false, false, false
+	}
+	key, ok := keyByResourceKey[keyFunc(namespace, name)]
+	return key, ok, true, ok && c.suspendedKeys[key]
+}
+
+// resolveStray returns the key of the locked ObjectSet looking for stray objects that an object claims to belong to, if any
+func (c *lockableObjectSetRegisterAndCache) resolveStray(obj runtime.Object) (relatedresource.Key, bool) {
+	key, ok := releaseKeyOf(obj)
+	if !ok {
+		return relatedresource.Key{}, false
+	}
+	s, ok := c.getState(key)
+	if !ok || !s.Locked || !s.config.StrayObjects.Enabled {
+		return relatedresource.Key{}, false
+	}
+	return key, true
+}
+
 // getState returns the underlying objectSetState for a given key
 func (c *lockableObjectSetRegisterAndCache) getState(key relatedresource.Key) (*objectSetState, bool) {
 	c.stateMapLock.RLock()
@@ -233,7 +425,8 @@ func (c *lockableObjectSetRegisterAndCache) getState(key relatedresource.Key) (*
 }
 
 // setState allows a user to set the objectSetState for a given key
-func (c *lockableObjectSetRegisterAndCache) setState(key relatedresource.Key, os *objectset.ObjectSet, locked *bool, forceEnqueue bool) {
+// mutate allows modifying any other settings of the objectSetState
+func (c *lockableObjectSetRegisterAndCache) setState(key relatedresource.Key, os *objectset.ObjectSet, locked *bool, mutate func(s *objectSetState), forceEnqueue bool) {
 	// get old state and use as the base
 	originalState, modifying := c.getState(key)
 	var s *objectSetState
@@ -250,15 +443,29 @@ func (c *lockableObjectSetRegisterAndCache) setState(key relatedresource.Key, os
 	// apply provided settings or use original state as default
 	if os != nil {
 		s.ObjectSet = os
+		osDigest, err := digest(os)
+		if err != nil {
+			logrus.Errorf("unable to compute digest of objectset for %s/%s: %s", key.Namespace, key.Name, err)
+		}
+		if err != nil || !modifying || osDigest != originalState.digest {
+			s.digest = osDigest
+			s.modifiedAt = time.Now()
+		}
 	}
 	if locked != nil {
 		s.Locked = *locked
 	}
+	if mutate != nil {
+		mutate(s)
+	}
 
 	// do nothing if the object has not changed
 	objectChanged := forceEnqueue || !modifying
 	if modifying {
 		objectChanged = objectChanged || s.ObjectSet != originalState.ObjectSet || s.Locked != originalState.Locked
+		objectChanged = objectChanged || !reflect.DeepEqual(s.config, originalState.config)
+		objectChanged = objectChanged || !reflect.DeepEqual(s.sources, originalState.sources)
+		objectChanged = objectChanged || s.released != originalState.released
 	}
 	if !objectChanged {
 		return
@@ -286,25 +493,52 @@ func (c *lockableObjectSetRegisterAndCache) deleteState(key relatedresource.Key)
 	c.stateMapLock.Lock()
 	delete(c.stateByKey, key)
 	c.stateMapLock.Unlock()
+	c.takeTriggers(key)
 	s.mutateMu.Lock()
 	s.ObjectSet = nil
 	s.mutateMu.Unlock()
 	s.Locked = false
 	c.stateChanges <- watch.Event{Type: watch.Deleted, Object: s}
+
+	// allow objectsets that could not lock objects tracked by this objectset to lock them
+	for _, contender := range c.removeConflicts(key) {
+		c.Enqueue(contender.Namespace, contender.Name)
+	}
 }
 
 // lock adds entries to the register to ensure that resources tracked by this ObjectSet are resolved to this ObjectSet
-func (c *lockableObjectSetRegisterAndCache) lock(key relatedresource.Key, os *objectset.ObjectSet) error {
+// Resources that are already tracked by another ObjectSet are resolved based on the conflictPolicy
+func (c *lockableObjectSetRegisterAndCache) lock(key relatedresource.Key, s *objectSetState) ([]Conflict, error) {
 	c.keyMapLock.Lock()
 	defer c.keyMapLock.Unlock()
 
-	if err := c.canLock(key, os); err != nil {
-		return err
+	c.removeAllEntries(key)
+	delete(c.conflictsByContender, key)
+
+	conflicts := c.conflicts(key, s.ObjectSet)
+	if len(conflicts) > 0 && c.conflictPolicy == RefuseConflictPolicy {
+		// do not lock any objects in this ObjectSet
+		c.conflictsByContender[key] = conflicts
+		return conflicts, nil
 	}
 
-	c.removeAllEntries(key)
+	lost := make(map[schema.GroupVersionKind]map[relatedresource.Key]bool)
+	for i, conflict := range conflicts {
+		if c.conflictPolicy == NewestWinsConflictPolicy && c.isNewer(s, conflict.Owner) {
+			// take over the object from the ObjectSet that currently locks it
+			conflict.Owner, conflict.Contender = key, conflict.Owner
+			conflicts[i] = conflict
+			c.conflictsByContender[conflict.Contender] = append(c.conflictsByContender[conflict.Contender], conflict)
+			continue
+		}
+		if _, ok := lost[conflict.GVK]; !ok {
+			lost[conflict.GVK] = make(map[relatedresource.Key]bool)
+		}
+		lost[conflict.GVK][keyFunc(conflict.Key.Namespace, conflict.Key.Name)] = true
+		c.conflictsByContender[key] = append(c.conflictsByContender[key], conflict)
+	}
 
-	objectsByGVK := os.ObjectsByGVK()
+	objectsByGVK := s.ObjectSet.ObjectsByGVK()
 
 	for gvk, objMap := range objectsByGVK {
 		keyByResourceKey, ok := c.keyByResourceKeyByGVK[gvk]
@@ -313,17 +547,21 @@ func (c *lockableObjectSetRegisterAndCache) lock(key relatedresource.Key, os *ob
 		}
 		for objKey := range objMap {
 			resourceKey := keyFunc(objKey.Namespace, objKey.Name)
+			if lost[gvk][resourceKey] {
+				// object stays locked by another set
+				continue
+			}
 			keyByResourceKey[resourceKey] = key
 		}
 		c.keyByResourceKeyByGVK[gvk] = keyByResourceKey
 
 		// ensure that we are watching this new GVK
 		if err := c.gvkWatcher.Watch(gvk); err != nil {
-			return err
+			return conflicts, err
 		}
 	}
 
-	return nil
+	return conflicts, nil
 }
 
 // unlock removes all entries to the register tied to a particular ObjectSet by key
@@ -334,9 +572,10 @@ func (c *lockableObjectSetRegisterAndCache) unlock(key relatedresource.Key) {
 	c.removeAllEntries(key)
 }
 
-// canLock returns whether trynig to lock the provided ObjectSet will result in an error
-// One of the few reasons why this is possible is if two registered ObjectSets are attempting to track the same resource
-func (c *lockableObjectSetRegisterAndCache) canLock(key relatedresource.Key, os *objectset.ObjectSet) error {
+// conflicts returns a Conflict for each resource tracked by the provided ObjectSet that is already locked by another ObjectSet
+// Note: This is a thread-unsafe function that expects the keyMapLock to be held
+func (c *lockableObjectSetRegisterAndCache) conflicts(key relatedresource.Key, os *objectset.ObjectSet) []Conflict {
+	var conflicts []Conflict
 	objectsByGVK := os.ObjectsByGVK()
 	for gvk, objMap := range objectsByGVK {
 		keyByResourceKey, ok := c.keyByResourceKeyByGVK[gvk]
@@ -348,11 +587,56 @@ func (c *lockableObjectSetRegisterAndCache) canLock(key relatedresource.Key, os
 			currKey, ok := keyByResourceKey[resourceKey]
 			if ok && currKey != key {
 				// object is already associated with another set
-				return fmt.Errorf("cannot lock objectset for %s: object %s is already associated with key %s", key, objKey, currKey)
+				conflicts = append(conflicts, Conflict{
+					GVK:       gvk,
+					Key:       objKey,
+					Owner:     currKey,
+					Contender: key,
+				})
 			}
 		}
 	}
-	return nil
+	return conflicts
+}
+
+// isNewer returns whether the contents of the provided objectSetState were set more recently than those of the ObjectSet tied to other
+func (c *lockableObjectSetRegisterAndCache) isNewer(s *objectSetState, other relatedresource.Key) bool {
+	otherState, ok := c.getState(other)
+	if !ok {
+		return true
+	}
+	return s.modifiedAt.After(otherState.modifiedAt)
+}
+
+// removeConflicts removes all entries to the register and all conflicts that involve a particular ObjectSet by key and
+// returns the keys of the ObjectSets that could not lock objects locked by it
+//
+// Entries are removed along with the conflicts so that the returned ObjectSets can lock the objects once they are enqueued
+func (c *lockableObjectSetRegisterAndCache) removeConflicts(key relatedresource.Key) []relatedresource.Key {
+	c.keyMapLock.Lock()
+	defer c.keyMapLock.Unlock()
+
+	c.removeAllEntries(key)
+	delete(c.conflictsByContender, key)
+	var contenders []relatedresource.Key
+	for contender, conflicts := range c.conflictsByContender {
+		var remaining []Conflict
+		for _, conflict := range conflicts {
+			if conflict.Owner != key {
+				remaining = append(remaining, conflict)
+			}
+		}
+		if len(remaining) == len(conflicts) {
+			continue
+		}
+		contenders = append(contenders, contender)
+		if len(remaining) == 0 {
+			delete(c.conflictsByContender, contender)
+		} else {
+			c.conflictsByContender[contender] = remaining
+		}
+	}
+	return contenders
 }
 
 // removeAllEntries removes all entries to the register tied to a particular ObjectSet by key
@@ -0,0 +1,129 @@
+package objectset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// noopWatcher is a gvk.Watcher that does not watch anything
+type noopWatcher struct{}
+
+func (noopWatcher) Start(context.Context, int) error { return nil }
+
+func (noopWatcher) Watch(schema.GroupVersionKind) error { return nil }
+
+// newTestRegister returns a register that tracks an ObjectSet containing the shared ConfigMap for each of the provided keys,
+// where each ObjectSet was set more recently than the one before it
+func newTestRegister(conflictPolicy ConflictPolicy, keys ...relatedresource.Key) *lockableObjectSetRegisterAndCache {
+	c := &lockableObjectSetRegisterAndCache{
+		stateByKey:            make(map[relatedresource.Key]*objectSetState),
+		keyByResourceKeyByGVK: make(map[schema.GroupVersionKind]map[relatedresource.Key]relatedresource.Key),
+		conflictsByContender:  make(map[relatedresource.Key][]Conflict),
+		triggersByKey:         make(map[relatedresource.Key]objectKeysByGVK),
+		suspendedKeys:         make(map[relatedresource.Key]bool),
+		conflictPolicy:        conflictPolicy,
+		gvkWatcher:            noopWatcher{},
+	}
+	modifiedAt := time.Now()
+	for _, key := range keys {
+		s := newObjectSetState(key.Namespace, key.Name, objectSetState{})
+		s.ObjectSet = objectset.NewObjectSet(newConfigMap("default", "shared", nil))
+		s.modifiedAt = modifiedAt
+		modifiedAt = modifiedAt.Add(time.Second)
+		c.stateByKey[key] = s
+	}
+	return c
+}
+
+// owner returns the key of the ObjectSet that locks the shared ConfigMap
+func owner(t *testing.T, c *lockableObjectSetRegisterAndCache) relatedresource.Key {
+	t.Helper()
+	key, ok, _, _ := c.resolveTracked(configMapGVK, "default", "shared")
+	if !ok {
+		t.Fatal("expected the shared ConfigMap to be locked")
+	}
+	return key
+}
+
+func TestLockKeepsClaimAcrossLocks(t *testing.T) {
+	first := relatedresource.Key{Namespace: "foo", Name: "first"}
+	second := relatedresource.Key{Namespace: "foo", Name: "second"}
+	c := newTestRegister(FirstWinsConflictPolicy, first, second)
+
+	c.Lock(first)
+	// the owner is re-locked while it is applied, and the contender is locked in between
+	c.suspend(first)
+	conflicts := c.Lock(second)
+	c.Lock(first)
+	c.resume(first)
+	if owner(t, c) != first {
+		t.Errorf("expected the objectset that locked the object first to keep it, got %v", owner(t, c))
+	}
+	objs := contested(second, c.stateByKey[second].ObjectSet, conflicts)
+	if len(objs) != 1 {
+		t.Errorf("expected the shared object to not be applied for the contender, got %d contested objects", len(objs))
+	}
+	if objs := contested(first, c.stateByKey[first].ObjectSet, conflicts); len(objs) != 0 {
+		t.Errorf("expected all objects to be applied for the owner, got %d contested objects", len(objs))
+	}
+
+	// the contender locks the object once the owner is deleted
+	c.removeConflicts(first)
+	if conflicts := c.Lock(second); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts once the owner is deleted, got %v", conflicts)
+	}
+	if owner(t, c) != second {
+		t.Errorf("expected the contender to lock the object once the owner is deleted, got %v", owner(t, c))
+	}
+}
+
+func TestLockNewestWins(t *testing.T) {
+	first := relatedresource.Key{Namespace: "foo", Name: "first"}
+	second := relatedresource.Key{Namespace: "foo", Name: "second"}
+	c := newTestRegister(NewestWinsConflictPolicy, first, second)
+
+	c.Lock(first)
+	conflicts := c.Lock(second)
+	if owner(t, c) != second {
+		t.Errorf("expected the newest objectset to take over the object, got %v", owner(t, c))
+	}
+	if objs := contested(second, c.stateByKey[second].ObjectSet, conflicts); len(objs) != 0 {
+		t.Errorf("expected all objects to be applied for the newest objectset, got %d contested objects", len(objs))
+	}
+	conflicts = c.Lock(first)
+	if owner(t, c) != second {
+		t.Errorf("expected re-locking the older objectset to not take the object back, got %v", owner(t, c))
+	}
+	if objs := contested(first, c.stateByKey[first].ObjectSet, conflicts); len(objs) != 1 {
+		t.Errorf("expected the shared object to not be applied for the older objectset, got %d contested objects", len(objs))
+	}
+}
+
+func TestResolveSuspended(t *testing.T) {
+	key := relatedresource.Key{Namespace: "foo", Name: "foochart"}
+	c := newTestRegister(FirstWinsConflictPolicy, key)
+	c.Lock(key)
+
+	c.suspend(key)
+	keys, err := c.Resolve(configMapGVK, "default", "shared", newConfigMap("default", "shared", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected changes to objects of a suspended objectset to not be resolved, got %v", keys)
+	}
+
+	c.resume(key)
+	keys, err = c.Resolve(configMapGVK, "default", "shared", newConfigMap("default", "shared", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != key {
+		t.Errorf("expected changes to objects of a resumed objectset to be resolved to it, got %v", keys)
+	}
+}
@@ -0,0 +1,16 @@
+package objectset
+
+// Config configures how a specific ObjectSet is locked, overriding the LockOptions of the register
+type Config struct {
+	// FlapDetection overrides when objects of the ObjectSet are contested, if provided
+	FlapDetection *FlapDetection
+	// Recreate determines which objects of the ObjectSet are re-created if reverting changes to them fails,
+	// in addition to those re-created based on the LockOptions of the register
+	Recreate Recreate
+	// StrayObjects configures whether objects that claim to belong to the Helm release of the ObjectSet but are not
+	// tracked by it are reported or pruned
+	StrayObjects StrayObjects
+	// Incomplete indicates that the ObjectSet does not contain every object of its release (e.g. since some documents of
+	// its manifest could not be parsed), so objects that are not tracked by it are neither pruned nor reported as strays
+	Incomplete bool
+}
@@ -0,0 +1,70 @@
+package objectset
+
+import (
+	"fmt"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ConflictPolicy determines which ObjectSet locks an object that is tracked by more than one ObjectSet
+type ConflictPolicy string
+
+const (
+	// FirstWinsConflictPolicy keeps an object locked by the ObjectSet that locked it first
+	FirstWinsConflictPolicy ConflictPolicy = "first-wins"
+
+	// NewestWinsConflictPolicy locks an object by the ObjectSet that was most recently set with new contents
+	NewestWinsConflictPolicy ConflictPolicy = "newest-wins"
+
+	// RefuseConflictPolicy refuses to lock any object of an ObjectSet that tracks an object already locked by another ObjectSet
+	RefuseConflictPolicy ConflictPolicy = "refuse"
+
+	// DefaultConflictPolicy is the ConflictPolicy used if none is provided
+	DefaultConflictPolicy = FirstWinsConflictPolicy
+)
+
+// Validate returns an error if the ConflictPolicy is not supported
+func (p ConflictPolicy) Validate() error {
+	switch p {
+	case FirstWinsConflictPolicy, NewestWinsConflictPolicy, RefuseConflictPolicy:
+		return nil
+	default:
+		return fmt.Errorf("invalid conflict policy %q: must be one of %s, %s, or %s", p, FirstWinsConflictPolicy, NewestWinsConflictPolicy, RefuseConflictPolicy)
+	}
+}
+
+// Conflict describes an object that is tracked by more than one ObjectSet
+type Conflict struct {
+	// GVK is the GroupVersionKind of the object
+	GVK schema.GroupVersionKind
+	// Key identifies the object
+	Key objectset.ObjectKey
+	// Owner is the key of the ObjectSet that locks the object
+	Owner relatedresource.Key
+	// Contender is the key of the ObjectSet that also tracks the object but does not lock it
+	Contender relatedresource.Key
+}
+
+// String returns a description of the Conflict
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s %s is locked by %s/%s", c.GVK.Kind, c.Key, c.Owner.Namespace, c.Owner.Name)
+}
+
+// contested returns the objects of the ObjectSet tied to key that are locked by another ObjectSet, which should not be
+// applied for it
+func contested(key relatedresource.Key, os *objectset.ObjectSet, conflicts []Conflict) []runtime.Object {
+	var objs []runtime.Object
+	objectsByGVK := os.ObjectsByGVK()
+	for _, conflict := range conflicts {
+		if conflict.Contender != key {
+			continue
+		}
+		if obj, ok := objectsByGVK[conflict.GVK][conflict.Key]; ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/rancher/helm-locker/pkg/gvk"
 	"github.com/rancher/helm-locker/pkg/informerfactory"
 	"github.com/rancher/lasso/pkg/controller"
 	"github.com/rancher/wrangler/v3/pkg/apply"
@@ -15,17 +14,26 @@ import (
 
 // NewLockableRegister returns a starter that starts an ObjectSetController listening to events on ObjectSetStates
 // and a LockableRegister that allows you to register new states for ObjectSets in memory
-func NewLockableRegister(name string, apply apply.Apply, scf controller.SharedControllerFactory, discovery discovery.DiscoveryInterface, opts *controller.Options) (start.Starter, LockableRegister, *controller.SharedHandler) {
+func NewLockableRegister(name string, apply apply.Apply, scf controller.SharedControllerFactory, discovery discovery.DiscoveryInterface, lockOpts LockOptions, opts *controller.Options) (start.Starter, LockableRegister, *controller.SharedHandler) {
 	// Define a new cache
 	apply = apply.WithCacheTypeFactory(informerfactory.New(scf))
 
+	lockOpts = lockOpts.applyDefaults()
+
 	handler := handler{
 		apply:         apply,
-		gvkLister:     gvk.NewLister(discovery),
+		clientFactory: scf.SharedCacheFactory().SharedClientFactory(),
+		flapDetection: lockOpts.FlapDetection,
+		applyMode:     lockOpts.ApplyMode,
+		fieldManager:  lockOpts.FieldManager,
+		recreate:      lockOpts.Recreate,
+		normalizers:   lockOpts.Normalizers,
 		sharedHandler: &controller.SharedHandler{},
+
+		appliedBySetID: make(map[string]*appliedState),
 	}
 
-	lockableObjectSetRegister, objectSetCache := newLockableObjectSetRegisterAndCache(scf, handler.OnRemove)
+	lockableObjectSetRegister, objectSetCache := newLockableObjectSetRegisterAndCache(scf, lockOpts.ConflictPolicy, handler.OnRemove)
 
 	handler.locker = lockableObjectSetRegister
 
@@ -36,6 +44,7 @@ func NewLockableRegister(name string, apply apply.Apply, scf controller.SharedCo
 
 	// Define a new controller that responds to events from the cache
 	objectSetController := controller.New(name, objectSetCache, startCache, &handler, applyDefaultOptions(opts))
+	handler.enqueueAfter = objectSetController.EnqueueAfter
 
 	return wrapStarter(objectSetController), lockableObjectSetRegister, handler.sharedHandler
 }
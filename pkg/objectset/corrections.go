@@ -0,0 +1,214 @@
+package objectset
+
+import (
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Correction describes how often an object tracked by an ObjectSet has been reverted since the ObjectSet was locked
+type Correction struct {
+	// Count is the number of times the object has been reverted
+	Count int
+	// LastCorrected is the last time the object was reverted
+	LastCorrected time.Time
+	// Contested is whether the object was reverted too often and is no longer being reverted
+	Contested bool
+	// Unenforceable is whether the object never matched the ObjectSet after being reverted and is no longer being reverted
+	Unenforceable bool
+	// DivergentPaths are the field paths that did not match the ObjectSet the last time the object was reverted
+	DivergentPaths []string
+
+	// recent are the times the object was reverted within the current flap detection window
+	recent []time.Time
+	// lastDrifted is the last time the object was found to have drifted, whether or not it was reverted
+	lastDrifted time.Time
+	// unconverged is the number of consecutive times the object did not match the ObjectSet after being reverted
+	unconverged int
+}
+
+// Enforced returns whether the object is still being reverted
+func (c Correction) Enforced() bool {
+	return !c.Contested && !c.Unenforceable
+}
+
+// Corrections keeps track of the Correction of each object tracked by an ObjectSet that has been reverted
+type Corrections map[schema.GroupVersionKind]map[objectset.ObjectKey]Correction
+
+// Get returns the Correction of an object, if it has ever been reverted
+func (c Corrections) Get(gvk schema.GroupVersionKind, key objectset.ObjectKey) (Correction, bool) {
+	correction, ok := c[gvk][key]
+	return correction, ok
+}
+
+// For returns the Correction of the object, if it has ever been reverted
+func (c Corrections) For(obj runtime.Object) (Correction, bool) {
+	objGVK, objKey, err := gvkAndKey(obj)
+	if err != nil {
+		return Correction{}, false
+	}
+	return c.Get(objGVK, objKey)
+}
+
+// Enforced returns whether an object is still being reverted
+func (c Corrections) Enforced(gvk schema.GroupVersionKind, key objectset.ObjectKey) bool {
+	return c[gvk][key].Enforced()
+}
+
+// anyUnenforced returns whether any object of a GVK is no longer being reverted
+func (c Corrections) anyUnenforced(gvk schema.GroupVersionKind) bool {
+	for _, correction := range c[gvk] {
+		if !correction.Enforced() {
+			return true
+		}
+	}
+	return false
+}
+
+// numContested returns the number of objects that are contested
+func (c Corrections) numContested() int {
+	contested := 0
+	for _, correctionByKey := range c {
+		for _, correction := range correctionByKey {
+			if correction.Contested {
+				contested++
+			}
+		}
+	}
+	return contested
+}
+
+// numUnenforceable returns the number of objects that are unenforceable
+func (c Corrections) numUnenforceable() int {
+	unenforceable := 0
+	for _, correctionByKey := range c {
+		for _, correction := range correctionByKey {
+			if correction.Unenforceable {
+				unenforceable++
+			}
+		}
+	}
+	return unenforceable
+}
+
+// verified records the field paths that did not match the ObjectSet after an object was reverted and
+// returns whether this resulted in the object being unenforceable
+func (c Corrections) verified(gvk schema.GroupVersionKind, key objectset.ObjectKey, divergentPaths []string) bool {
+	correction, ok := c[gvk][key]
+	if !ok {
+		return false
+	}
+	correction.DivergentPaths = divergentPaths
+	if len(divergentPaths) == 0 {
+		correction.unconverged = 0
+	} else {
+		correction.unconverged++
+	}
+	unenforceable := !correction.Unenforceable && correction.unconverged >= maxUnconvergedApplies
+	correction.Unenforceable = correction.Unenforceable || unenforceable
+	c[gvk][key] = correction
+	return unenforceable
+}
+
+// record records that an object was reverted at the provided time and returns whether
+// this resulted in the object being contested based on the provided FlapDetection
+func (c Corrections) record(gvk schema.GroupVersionKind, key objectset.ObjectKey, at time.Time, flapDetection FlapDetection) bool {
+	correctionByKey, ok := c[gvk]
+	if !ok {
+		correctionByKey = make(map[objectset.ObjectKey]Correction)
+		c[gvk] = correctionByKey
+	}
+	correction := correctionByKey[key]
+	correction.Count++
+	correction.LastCorrected = at
+	correction.lastDrifted = at
+
+	contested := false
+	if flapDetection.Enabled() {
+		recent := []time.Time{at}
+		for _, t := range correction.recent {
+			if at.Sub(t) < flapDetection.Window {
+				recent = append(recent, t)
+			}
+		}
+		correction.recent = recent
+		contested = !correction.Contested && len(recent) > flapDetection.Threshold
+		correction.Contested = correction.Contested || contested
+	}
+
+	correctionByKey[key] = correction
+	return contested
+}
+
+// drifted records that an object that is no longer being reverted was found to have drifted at the provided time
+func (c Corrections) drifted(gvk schema.GroupVersionKind, key objectset.ObjectKey, at time.Time) {
+	correction, ok := c[gvk][key]
+	if !ok {
+		return
+	}
+	correction.lastDrifted = at
+	c[gvk][key] = correction
+}
+
+// settle enforces contested objects again once they have not drifted for the window of the provided FlapDetection
+// and returns the objects that are enforced again
+func (c Corrections) settle(at time.Time, flapDetection FlapDetection) objectKeysByGVK {
+	settled := objectKeysByGVK{}
+	for gvk, correctionByKey := range c {
+		for key, correction := range correctionByKey {
+			if !correction.Contested || at.Sub(correction.lastDrifted) < flapDetection.Window {
+				continue
+			}
+			correction.Contested = false
+			correction.recent = nil
+			correctionByKey[key] = correction
+			settled.add(gvk, key)
+		}
+	}
+	return settled
+}
+
+// nextSettle returns how long it will take for the first contested object to be enforced again if it does not drift,
+// if any object is contested
+func (c Corrections) nextSettle(at time.Time, flapDetection FlapDetection) (time.Duration, bool) {
+	var next time.Duration
+	found := false
+	for _, correctionByKey := range c {
+		for _, correction := range correctionByKey {
+			if !correction.Contested {
+				continue
+			}
+			remaining := correction.lastDrifted.Add(flapDetection.Window).Sub(at)
+			if !found || remaining < next {
+				next, found = remaining, true
+			}
+		}
+	}
+	return next, found
+}
+
+// DeepCopy returns a copy of the Corrections
+func (c Corrections) DeepCopy() Corrections {
+	if c == nil {
+		return nil
+	}
+	out := make(Corrections, len(c))
+	for gvk, correctionByKey := range c {
+		outByKey := make(map[objectset.ObjectKey]Correction, len(correctionByKey))
+		for key, correction := range correctionByKey {
+			outByKey[key] = correction
+		}
+		out[gvk] = outByKey
+	}
+	return out
+}
+
+// appliedState keeps track of the last ObjectSet that was successfully applied for a setID
+type appliedState struct {
+	// digest is the digest of the ObjectSet that was applied
+	digest string
+	// corrections are the corrections made to objects since the ObjectSet with this digest was first applied
+	corrections Corrections
+}
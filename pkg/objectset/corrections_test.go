@@ -0,0 +1,146 @@
+package objectset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+)
+
+func TestCorrectionsRecord(t *testing.T) {
+	key := objectset.ObjectKey{Namespace: "default", Name: "foo"}
+	start := time.Now()
+	testCases := []struct {
+		name          string
+		flapDetection FlapDetection
+		// offsets are the times after start at which the object is reverted
+		offsets []time.Duration
+		// contestedAt is the index of the revert that is expected to contest the object, or -1 if none is
+		contestedAt int
+	}{
+		{
+			name:          "reverts within the threshold",
+			flapDetection: FlapDetection{Threshold: 3, Window: time.Minute},
+			offsets:       []time.Duration{0, time.Second, 2 * time.Second},
+			contestedAt:   -1,
+		},
+		{
+			name:          "reverts exceeding the threshold",
+			flapDetection: FlapDetection{Threshold: 3, Window: time.Minute},
+			offsets:       []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second},
+			contestedAt:   3,
+		},
+		{
+			name:          "reverts outside of the window",
+			flapDetection: FlapDetection{Threshold: 2, Window: time.Minute},
+			offsets:       []time.Duration{0, 30 * time.Second, 60 * time.Second, 90 * time.Second, 120 * time.Second},
+			contestedAt:   -1,
+		},
+		{
+			name:          "reverts exceeding the threshold within a later window",
+			flapDetection: FlapDetection{Threshold: 2, Window: time.Minute},
+			offsets:       []time.Duration{0, 2 * time.Minute, 2*time.Minute + time.Second, 2*time.Minute + 2*time.Second},
+			contestedAt:   3,
+		},
+		{
+			name:          "disabled flap detection",
+			flapDetection: FlapDetection{},
+			offsets:       []time.Duration{0, 0, 0, 0, 0, 0},
+			contestedAt:   -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			corrections := Corrections{}
+			for i, offset := range tc.offsets {
+				at := start.Add(offset)
+				contested := corrections.record(configMapGVK, key, at, tc.flapDetection)
+				if contested != (i == tc.contestedAt) {
+					t.Errorf("expected revert %d to contest the object: %t, got %t", i, i == tc.contestedAt, contested)
+				}
+				correction, _ := corrections.Get(configMapGVK, key)
+				if correction.Count != i+1 || !correction.LastCorrected.Equal(at) {
+					t.Errorf("expected revert %d to be counted, got count %d last corrected at %s", i, correction.Count, correction.LastCorrected)
+				}
+			}
+			correction, _ := corrections.Get(configMapGVK, key)
+			if correction.Contested != (tc.contestedAt >= 0) || correction.Enforced() == (tc.contestedAt >= 0) {
+				t.Errorf("expected the object to be contested: %t, got %t", tc.contestedAt >= 0, correction.Contested)
+			}
+		})
+	}
+}
+
+func TestCorrectionsVerified(t *testing.T) {
+	key := objectset.ObjectKey{Namespace: "default", Name: "foo"}
+	diverged := []string{"data.foo"}
+
+	corrections := Corrections{}
+	if corrections.verified(configMapGVK, key, diverged) {
+		t.Error("expected an object that was never reverted to not become unenforceable")
+	}
+
+	corrections.record(configMapGVK, key, time.Now(), FlapDetection{})
+	for i := 1; i < maxUnconvergedApplies; i++ {
+		if corrections.verified(configMapGVK, key, diverged) {
+			t.Errorf("expected the object to not be unenforceable after %d unconverged applies", i)
+		}
+	}
+	// converging resets the count of consecutive unconverged applies
+	corrections.verified(configMapGVK, key, nil)
+	for i := 1; i < maxUnconvergedApplies; i++ {
+		if corrections.verified(configMapGVK, key, diverged) {
+			t.Errorf("expected the object to not be unenforceable after %d unconverged applies following a converged apply", i)
+		}
+	}
+	if !corrections.verified(configMapGVK, key, diverged) {
+		t.Errorf("expected the object to become unenforceable after %d unconverged applies", maxUnconvergedApplies)
+	}
+	if corrections.verified(configMapGVK, key, diverged) {
+		t.Error("expected the object to only become unenforceable once")
+	}
+	correction, _ := corrections.Get(configMapGVK, key)
+	if !correction.Unenforceable || correction.Enforced() {
+		t.Error("expected the object to no longer be enforced")
+	}
+	if len(correction.DivergentPaths) != 1 || correction.DivergentPaths[0] != "data.foo" {
+		t.Errorf("expected the divergent paths of the last apply to be recorded, got %v", correction.DivergentPaths)
+	}
+}
+
+func TestCorrectionsSettle(t *testing.T) {
+	key := objectset.ObjectKey{Namespace: "default", Name: "foo"}
+	flapDetection := FlapDetection{Threshold: 1, Window: time.Minute}
+	start := time.Now()
+
+	corrections := Corrections{}
+	corrections.record(configMapGVK, key, start, flapDetection)
+	if !corrections.record(configMapGVK, key, start.Add(time.Second), flapDetection) {
+		t.Fatal("expected the object to be contested")
+	}
+	next, ok := corrections.nextSettle(start.Add(time.Second), flapDetection)
+	if !ok || next != time.Minute {
+		t.Errorf("expected the object to be enforced again after the window, got %s", next)
+	}
+
+	// drift found while the object is contested restarts the window
+	corrections.drifted(configMapGVK, key, start.Add(30*time.Second))
+	if settled := corrections.settle(start.Add(time.Minute+time.Second), flapDetection); len(settled) != 0 {
+		t.Errorf("expected the object to stay contested while it drifts, got %v", settled)
+	}
+	settled := corrections.settle(start.Add(90*time.Second), flapDetection)
+	if !settled[configMapGVK][key] {
+		t.Errorf("expected the object to be enforced again once it did not drift for the window, got %v", settled)
+	}
+	if !corrections.Enforced(configMapGVK, key) {
+		t.Error("expected the object to be enforced")
+	}
+	if _, ok := corrections.nextSettle(start.Add(90*time.Second), flapDetection); ok {
+		t.Error("expected no contested objects to be left")
+	}
+
+	// reverts before the object was contested no longer count towards the threshold
+	if corrections.record(configMapGVK, key, start.Add(91*time.Second), flapDetection) {
+		t.Error("expected the object to not be contested again on its first revert")
+	}
+}
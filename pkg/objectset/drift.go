@@ -0,0 +1,72 @@
+package objectset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Drift describes an object tracked by an ObjectSet that had drifted from it and was reverted
+type Drift struct {
+	// Object is the object as it existed in the cluster before it was reverted, or the desired object if it had been deleted
+	Object runtime.Object
+	// Actor is the field manager that last modified the object before it was reverted, if known
+	Actor string
+	// Digest is a digest of the changes that were reverted
+	Digest string
+	// Time is the time the object was reverted
+	Time time.Time
+	// Existing is whether the object existed in the cluster before it was reverted
+	Existing bool
+}
+
+// newUpdateDrift returns the Drift of an existing object that was patched to match the ObjectSet
+func newUpdateDrift(existing runtime.Object, patch string) Drift {
+	return Drift{
+		Object:   existing,
+		Actor:    lastManager(existing),
+		Digest:   digestBytes([]byte(patch)),
+		Existing: true,
+	}
+}
+
+// newCreateDrift returns the Drift of a deleted object that was re-created to match the ObjectSet
+func newCreateDrift(desired runtime.Object) Drift {
+	drift := Drift{
+		Object: desired,
+	}
+	if data, err := json.Marshal(desired); err == nil {
+		drift.Digest = digestBytes(data)
+	}
+	return drift
+}
+
+// lastManager returns the field manager that most recently modified an object, based on its managedFields
+func lastManager(obj runtime.Object) string {
+	metadata, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	var manager string
+	var lastModified time.Time
+	for _, entry := range metadata.GetManagedFields() {
+		if entry.Time == nil {
+			continue
+		}
+		if !entry.Time.Time.Before(lastModified) {
+			manager = entry.Manager
+			lastModified = entry.Time.Time
+		}
+	}
+	return manager
+}
+
+// digestBytes returns the hex-encoded sha256 digest of data
+func digestBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
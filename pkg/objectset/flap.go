@@ -0,0 +1,50 @@
+package objectset
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultFlapThreshold is the default number of times an object can be reverted within the flap window before it is contested
+	// Flap detection is disabled by default, so objects are always reverted unless a threshold is configured
+	DefaultFlapThreshold = 0
+
+	// DefaultFlapWindow is the default window within which reverts of an object are counted towards the flap threshold
+	DefaultFlapWindow = 5 * time.Minute
+)
+
+// FlapDetection configures when an object that keeps being modified by another actor stops being enforced
+//
+// If an object is reverted more than Threshold times within Window, it is considered Contested and will no longer
+// be reverted until it has not drifted for Window or the ObjectSet is set with new contents.
+type FlapDetection struct {
+	// Threshold is the number of times an object can be reverted within Window; a Threshold of 0 disables flap detection
+	Threshold int
+	// Window is the window within which reverts of an object are counted
+	Window time.Duration
+}
+
+// DefaultFlapDetection returns the FlapDetection used if none is provided
+func DefaultFlapDetection() FlapDetection {
+	return FlapDetection{
+		Threshold: DefaultFlapThreshold,
+		Window:    DefaultFlapWindow,
+	}
+}
+
+// Enabled returns whether objects can be contested
+func (f FlapDetection) Enabled() bool {
+	return f.Threshold > 0 && f.Window > 0
+}
+
+// Validate returns an error if the FlapDetection is invalid
+func (f FlapDetection) Validate() error {
+	if f.Threshold < 0 {
+		return fmt.Errorf("flap threshold %d cannot be negative", f.Threshold)
+	}
+	if f.Window < 0 {
+		return fmt.Errorf("flap window %s cannot be negative", f.Window)
+	}
+	return nil
+}
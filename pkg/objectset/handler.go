@@ -1,44 +1,70 @@
 package objectset
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
-	"github.com/rancher/helm-locker/pkg/gvk"
+	"github.com/rancher/helm-locker/pkg/metrics"
+	"github.com/rancher/lasso/pkg/client"
 	"github.com/rancher/lasso/pkg/controller"
 	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
 	"github.com/rancher/wrangler/v3/pkg/relatedresource"
 	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// objectSetApplierID is the set ID used to apply ObjectSets
+	objectSetApplierID = "object-set-applier"
 )
 
 type handler struct {
-	apply     apply.Apply
-	gvkLister gvk.Lister
-	locker    Locker
+	apply         apply.Apply
+	locker        triggeringLocker
+	clientFactory client.SharedClientFactory
+
+	// flapDetection determines when objects are contested if it is not overridden for an ObjectSet
+	flapDetection FlapDetection
+	// applyMode determines how ObjectSets are applied
+	applyMode ApplyMode
+	// fieldManager is the field manager used to apply ObjectSets with server-side apply
+	fieldManager string
+	// recreate determines which objects are re-created if they cannot be updated to match their ObjectSet
+	recreate Recreate
+	// normalizers rewrite objects into the form that the API server stores them in before they are compared
+	normalizers Normalizers
 
 	// allows us to add hooks into triggering certain actions on reconciles, e.g. launching events
 	sharedHandler *controller.SharedHandler
-}
-
-// configureApply configures the apply object for the provided setID and objectSetState
-func (h *handler) configureApply(setID string, oss *objectSetState) apply.Apply {
-	apply := h.apply.
-		WithSetID("object-set-applier").
-		WithOwnerKey(setID, internalGroupVersion.WithKind("objectSetState"))
+	// enqueueAfter enqueues the objectSetState of an ObjectSet after a delay, e.g. to enforce contested objects again
+	enqueueAfter func(namespace, name string, delay time.Duration)
 
-	if oss != nil && oss.ObjectSet != nil {
-		apply = apply.WithGVK(oss.ObjectSet.GVKs()...)
-	} else {
-		// if we cannot infer the GVK from the provided object set, include all GVKs in the cache types
-		gvks, err := h.gvkLister.List()
-		if err != nil {
-			logrus.Errorf("unable to list GVKs to apply deletes on objects, objectset %s may require manual cleanup: %s", setID, err)
-		} else {
-			apply = apply.WithGVK(gvks...)
-		}
-	}
+	// appliedBySetID keeps track of the last ObjectSet that was successfully applied for each setID
+	// This is used to tell apart drift corrections from applying a newly registered ObjectSet
+	appliedBySetID map[string]*appliedState
+	// appliedLock is a lock on the appliedBySetID map
+	appliedLock sync.Mutex
+}
 
-	return apply
+// configureApply configures the apply object for the provided setID to apply objects of the provided GVKs
+//
+// Objects that are no longer tracked by the ObjectSet are never deleted by apply.Apply; they are pruned by pruneObjectSet
+// instead, which keeps objects annotated with helm.sh/resource-policy: keep in the cluster
+func (h *handler) configureApply(setID string, gvks ...schema.GroupVersionKind) apply.Apply {
+	return h.apply.
+		WithSetID(objectSetApplierID).
+		WithOwnerKey(setID, internalGroupVersion.WithKind("objectSetState")).
+		WithGVK(gvks...).
+		WithNoDelete()
 }
 
 // OnChange reconciles the resources tracked by an objectSetState
@@ -59,45 +85,323 @@ func (h *handler) OnChange(setID string, obj runtime.Object) error {
 	}
 
 	key := relatedresource.FromString(setID)
-	h.locker.Unlock(key) // ensure that apply does not trigger locking again
+	h.locker.suspend(key) // ensure that apply does not enqueue the objectset again
+	defer h.locker.resume(key)
+	triggers := h.locker.takeTriggers(key)
 
 	if !oss.Locked {
-		// nothing to do
+		h.locker.Unlock(key)
 		return nil
 	}
+	// claim the objects before applying them, so that objects locked by another objectset are not applied
+	conflicts := h.locker.Lock(key)
+
 	// Run the apply
-	defer h.locker.Lock(key)
+	// released objects are part of the digest since they are pruned differently
+	osDigest, err := digest(oss.ObjectSet, oss.released)
+	if err != nil {
+		return fmt.Errorf("failed to compute digest of objectset for %s: %s", setID, err)
+	}
+	flapDetection := h.flapDetection
+	if oss.config.FlapDetection != nil {
+		flapDetection = *oss.config.FlapDetection
+	}
+	applied := oss.DeepCopy()
+	applied.conflicts = conflicts
+	toApply := without(oss.ObjectSet, contested(key, oss.ObjectSet, conflicts))
+	state := h.getApplied(setID)
+	newObjectSet := state == nil || state.digest != osDigest
+	if newObjectSet {
+		// a new ObjectSet is being applied, so start tracking corrections from scratch
+		state = &appliedState{digest: osDigest, corrections: Corrections{}}
+	} else {
+		// the ObjectSet was already applied, so any changes made by this apply are reverting drift
+		settled := state.corrections.settle(time.Now(), flapDetection)
+		for objGVK, objKeys := range settled {
+			for objKey := range objKeys {
+				logrus.Infof("%s %s in objectset %s has not drifted within %s, reverting it again", objGVK.Kind, objKey, setID, flapDetection.Window)
+				// settled objects may have drifted while they were contested
+				if triggers == nil {
+					triggers = objectKeysByGVK{}
+				}
+				triggers.add(objGVK, objKey)
+			}
+		}
+		reverted, err := h.reverted(setID, oss, triggers)
+		if err != nil {
+			logrus.Errorf("unable to identify drifted objects in objectset %s: %s", setID, err)
+		}
+		var deletedUnenforced []runtime.Object
+		for _, drift := range reverted {
+			objGVK, objKey, err := gvkAndKey(drift.Object)
+			if err == nil && !state.corrections.Enforced(objGVK, objKey) {
+				// contested or unenforceable objects are no longer reverted
+				state.corrections.drifted(objGVK, objKey, time.Now())
+				if !drift.Existing {
+					deletedUnenforced = append(deletedUnenforced, drift.Object)
+				}
+				continue
+			}
+			applied.reverted = append(applied.reverted, drift)
+		}
+		toApply = without(toApply, deletedUnenforced)
+	}
 
 	logrus.Debugf("running apply for %s...", setID)
-	if err := h.configureApply(setID, oss).Apply(oss.ObjectSet); err != nil {
+	recreated, err := h.applyObjectSet(setID, oss, toApply, state.corrections)
+	if err != nil {
 		return fmt.Errorf("failed to apply objectset for %s: %s", setID, err)
 	}
+	if newObjectSet && !oss.config.Incomplete {
+		// objects can only stop being tracked when a new ObjectSet is applied
+		if err := h.pruneObjectSet(setID, oss); err != nil {
+			return fmt.Errorf("failed to prune objectset for %s: %s", setID, err)
+		}
+	}
+	applied.recreated = recreated
+	applied.strays, err = h.strays(setID, oss)
+	if err != nil {
+		logrus.Errorf("unable to prune stray objects of objectset %s: %s", setID, err)
+	}
+	now := time.Now()
+	desired := oss.ObjectSet.ObjectsByGVK()
+	for i := range applied.reverted {
+		applied.reverted[i].Time = now
+		obj := applied.reverted[i].Object
+		objGVK, objKey, err := gvkAndKey(obj)
+		if err != nil {
+			continue
+		}
+		if state.corrections.record(objGVK, objKey, now, flapDetection) {
+			logrus.Warnf("%s %s in objectset %s was reverted more than %d times within %s, no longer reverting it", objGVK.Kind, objKey, setID, flapDetection.Threshold, flapDetection.Window)
+			applied.contested = append(applied.contested, obj)
+			metrics.ContestedObjectsTotal.WithLabelValues(key.Namespace, key.Name, objGVK.Kind).Inc()
+			continue
+		}
+		// verify that the reverted object matches the ObjectSet to identify objects that can never be reverted
+		desiredObj, ok := desiredObject(desired, objGVK, objKey)
+		if !ok {
+			continue
+		}
+		divergentPaths, err := h.verify(objGVK, objKey.Namespace, objKey.Name, desiredObj)
+		if err != nil {
+			logrus.Errorf("unable to verify %s %s in objectset %s was reverted: %s", objGVK.Kind, objKey, setID, err)
+			continue
+		}
+		if state.corrections.verified(objGVK, objKey, divergentPaths) {
+			logrus.Warnf("%s %s in objectset %s does not match the objectset after being reverted %d times (%v), no longer reverting it", objGVK.Kind, objKey, setID, maxUnconvergedApplies, divergentPaths)
+			applied.unenforceable = append(applied.unenforceable, obj)
+		}
+	}
+	metrics.ContestedObjects.WithLabelValues(key.Namespace, key.Name).Set(float64(state.corrections.numContested()))
+	metrics.UnenforceableObjects.WithLabelValues(key.Namespace, key.Name).Set(float64(state.corrections.numUnenforceable()))
+	h.setApplied(setID, state)
+	applied.corrections = state.corrections.DeepCopy()
+	if next, ok := state.corrections.nextSettle(now, flapDetection); ok && h.enqueueAfter != nil {
+		// contested objects are enforced again once they stop drifting, which is only checked on applying the ObjectSet
+		h.enqueueAfter(key.Namespace, key.Name, next)
+	}
 
 	logrus.Infof("applied %s", setID)
 
-	go h.sharedHandler.OnChange(setID, obj)
+	go h.sharedHandler.OnChange(setID, applied)
 
 	return nil
 }
 
-// OnRemove cleans up the resources tracked by an objectSetState
-func (h *handler) OnRemove(setID string, purge bool) {
+// applyObjectSet applies the objects of an ObjectSet tracked by an objectSetState based on the applyMode of the handler
+//
+// Objects are applied one GVK at a time in the order Helm installs them, waiting for CustomResourceDefinitions to be
+// established before applying custom resources that they define.
+//
+// Returns the objects that were re-created since they could not be updated to match the ObjectSet
+func (h *handler) applyObjectSet(setID string, oss *objectSetState, os *objectset.ObjectSet, corrections Corrections) ([]runtime.Object, error) {
+	r, err := h.newRecreator(setID, oss)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, objGVK := range sortGVKs(oss.ObjectSet.GVKs(), releaseutil.InstallOrder) {
+		stage := objectsOfGVK(os, objGVK)
+		if stage.Len() == 0 {
+			continue
+		}
+		if h.applyMode == ServerSideApplyMode {
+			err = h.applyServerSide(setID, stage, corrections, r)
+		} else {
+			err = h.withPatchers(h.configureApply(setID, objGVK), stage, corrections, r).Apply(stage)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if objGVK == crdGVK {
+			if err := h.waitForCRDs(stage.All(), os); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return r.Recreated(), errors.Join(errs...)
+}
+
+// withPatchers configures the apply object to not patch objects that are no longer being reverted and to re-create
+// objects that cannot be patched to match the ObjectSet
+func (h *handler) withPatchers(apply apply.Apply, os *objectset.ObjectSet, corrections Corrections, r *recreator) apply.Apply {
+	for _, objGVK := range os.GVKs() {
+		if !r.recreate.Matches(objGVK) && !corrections.anyUnenforced(objGVK) {
+			// use the default patcher
+			continue
+		}
+		apply = apply.WithPatcher(objGVK, h.patcher(objGVK, corrections, r))
+	}
+	return apply
+}
+
+// patcher returns a patcher for objects of a GVK that only patches objects that are still being reverted and
+// re-creates objects that cannot be patched, if configured
+func (h *handler) patcher(objGVK schema.GroupVersionKind, corrections Corrections, r *recreator) apply.Patcher {
+	return func(namespace, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+		if !corrections.Enforced(objGVK, objectset.ObjectKey{Namespace: namespace, Name: name}) {
+			logrus.Debugf("skipping patch of unenforced %s %s/%s", objGVK.Kind, namespace, name)
+			return nil, nil
+		}
+		c, err := h.clientFactory.ForKind(objGVK)
+		if err != nil {
+			return nil, err
+		}
+		result := &unstructured.Unstructured{}
+		err = c.Patch(context.TODO(), namespace, name, pt, data, result, metav1.PatchOptions{})
+		if r.shouldRecreate(objGVK, err) {
+			return r.recreateObject(objGVK, namespace, name, r.create(objGVK, namespace, name, metav1.CreateOptions{}))
+		}
+		return result, err
+	}
+}
+
+// reverted returns the Drift of each object tracked by the objectSetState that would be modified or re-created on applying it
+//
+// Only the objects whose changes triggered the apply are compared with the ObjectSet, since any other object cannot
+// have drifted since the ObjectSet was last applied
+func (h *handler) reverted(setID string, oss *objectSetState, triggers objectKeysByGVK) ([]Drift, error) {
+	desired := oss.ObjectSet.ObjectsByGVK()
+	triggered := objectset.NewObjectSet()
+	for objGVK, objKeys := range triggers {
+		for objKey := range objKeys {
+			if obj, ok := desiredObject(desired, objGVK, objKey); ok {
+				triggered.Add(obj)
+			}
+		}
+	}
+	if triggered.Len() == 0 {
+		return nil, nil
+	}
+	if h.applyMode == ServerSideApplyMode {
+		// objects applied with server-side apply do not carry the last applied configuration that apply.Apply diffs against
+		return h.revertedServerSide(setID, triggered)
+	}
+	plan, err := h.configureApply(setID, triggered.GVKs()...).DryRun(triggered.All()...)
+	if err != nil {
+		return nil, err
+	}
+	existing := objectset.ObjectByGVK{}
+	for _, obj := range plan.Objects {
+		if _, err := existing.Add(obj); err != nil {
+			return nil, err
+		}
+	}
+	labels, annotations, err := ownerLabelsAndAnnotations(setID)
+	if err != nil {
+		return nil, err
+	}
+	var reverted []Drift
+	for gvk, objKeys := range plan.Create {
+		for _, objKey := range objKeys {
+			if obj, ok := desired[gvk][objKey]; ok {
+				reverted = append(reverted, newCreateDrift(obj))
+			}
+		}
+	}
+	for gvk, patchByObjKey := range plan.Update {
+		for objKey, patch := range patchByObjKey {
+			desiredObj, isDesired := desiredObject(desired, gvk, objKey)
+			obj, ok := existing[gvk][objKey]
+			if ok && isDesired {
+				// patches that only revert differences in form caused by the API server (see Normalizers) are not drift
+				paths, err := h.normalizers.divergentPaths(withOwnerMetadata(desiredObj, labels, annotations), obj)
+				if err != nil {
+					return nil, err
+				}
+				if len(paths) == 0 {
+					continue
+				}
+			}
+			if ok {
+				reverted = append(reverted, newUpdateDrift(obj, patch))
+			} else if isDesired {
+				reverted = append(reverted, newUpdateDrift(desiredObj, patch))
+			}
+		}
+	}
+	return reverted, nil
+}
+
+// withOwnerMetadata returns a copy of an object with the labels and annotations that apply.Apply adds to the objects of
+// an ObjectSet, so that changes to them are considered drift, or the object itself if it cannot be copied
+func withOwnerMetadata(obj runtime.Object, labels, annotations map[string]string) runtime.Object {
+	// the content of unstructured objects is not copied on conversion
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return obj
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	u.SetLabels(merge(u.GetLabels(), labels))
+	u.SetAnnotations(merge(u.GetAnnotations(), annotations))
+	return u
+}
+
+// getApplied returns the state of the last ObjectSet that was successfully applied for a setID
+func (h *handler) getApplied(setID string) *appliedState {
+	h.appliedLock.Lock()
+	defer h.appliedLock.Unlock()
+	return h.appliedBySetID[setID]
+}
+
+// setApplied records the state of the last ObjectSet that was successfully applied for a setID
+func (h *handler) setApplied(setID string, s *appliedState) {
+	h.appliedLock.Lock()
+	defer h.appliedLock.Unlock()
+	if s == nil {
+		delete(h.appliedBySetID, setID)
+		return
+	}
+	h.appliedBySetID[setID] = s
+}
+
+// OnRemove cleans up the resources tracked by an objectSetState, purging the resources of the provided GVKs
+func (h *handler) OnRemove(setID string, purgeGVKs []schema.GroupVersionKind) {
 	logrus.Debugf("on delete: %s", setID)
 
 	key := relatedresource.FromString(setID)
 
 	h.locker.Unlock(key)
+	h.setApplied(setID, nil)
+	metrics.ContestedObjects.DeleteLabelValues(key.Namespace, key.Name)
+	metrics.UnenforceableObjects.DeleteLabelValues(key.Namespace, key.Name)
 
-	if !purge {
+	if len(purgeGVKs) == 0 {
 		return
 	}
 
-	logrus.Debugf("running apply for %s...", setID)
-	if err := h.configureApply(setID, nil).ApplyObjects(); err != nil {
-		logrus.Errorf("failed to clean up objectset %s: %s", setID, err)
+	logrus.Debugf("running purge for %s...", setID)
+	// delete objects one GVK at a time in the order Helm uninstalls them
+	for _, objGVK := range sortGVKs(purgeGVKs, releaseutil.UninstallOrder) {
+		if err := h.purge(setID, objGVK); err != nil {
+			logrus.Errorf("failed to clean up %s of objectset %s: %s", objGVK.Kind, setID, err)
+		}
 	}
 
-	logrus.Infof("applied %s", setID)
+	logrus.Infof("purged %s", setID)
 
 	go h.sharedHandler.OnChange(setID, nil)
 }
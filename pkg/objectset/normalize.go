@@ -0,0 +1,274 @@
+package objectset
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AnyKind is the GroupKind of Normalizers that apply to objects of every kind
+var AnyKind = schema.GroupKind{Kind: "*"}
+
+// Normalizer rewrites an object into the form that the API server stores it in without changing its meaning
+// (e.g. dropping fields that are left empty for the API server to default or formatting quantities), so that
+// differences between the desired object and the object in the cluster that are caused by the API server are
+// never considered drift
+//
+// Normalizers are applied to copies of both objects on comparing them; objects are always applied as rendered.
+type Normalizer func(obj *unstructured.Unstructured) error
+
+// Normalizers are the Normalizers applied to objects of each GroupKind, or of every kind for AnyKind
+type Normalizers map[schema.GroupKind][]Normalizer
+
+// DefaultNormalizers returns Normalizers for well-known defaulting and formatting done by the API server on core kinds
+func DefaultNormalizers() Normalizers {
+	normalizers := Normalizers{}
+	normalizers.Add(AnyKind, dropNulls)
+	normalizers.Add(schema.GroupKind{Kind: "Service"}, normalizeService)
+	normalizers.Add(schema.GroupKind{Kind: "Secret"}, normalizeSecret)
+	normalizers.Add(schema.GroupKind{Kind: "PersistentVolumeClaim"}, normalizeQuantities("spec", "resources", "requests"), normalizeQuantities("spec", "resources", "limits"))
+	normalizers.Add(schema.GroupKind{Kind: "ResourceQuota"}, normalizeQuantities("spec", "hard"))
+	normalizers.Add(schema.GroupKind{Kind: "LimitRange"}, normalizeLimitRange)
+	for gk, podSpecPath := range podSpecPaths {
+		normalizers.Add(gk, normalizePodSpec(podSpecPath...))
+	}
+	return normalizers
+}
+
+// Add adds Normalizers for objects of a GroupKind, which are applied after any Normalizers already added for it
+func (n Normalizers) Add(gk schema.GroupKind, normalizers ...Normalizer) {
+	n[gk] = append(n[gk], normalizers...)
+}
+
+// divergentPaths returns the field paths of a desired object whose values do not match an object in the cluster once
+// both are normalized
+//
+// Objects are only normalized to be compared, since they are applied as they are
+func (n Normalizers) divergentPaths(desired, live runtime.Object) ([]string, error) {
+	desiredContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(n.normalizeObject(desired))
+	if err != nil {
+		return nil, err
+	}
+	liveContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(n.normalizeObject(live))
+	if err != nil {
+		return nil, err
+	}
+	return divergentPaths(desiredContent, liveContent), nil
+}
+
+// normalizeObject returns a normalized copy of an object, or the object itself if it cannot be normalized
+func (n Normalizers) normalizeObject(obj runtime.Object) runtime.Object {
+	objGVK := obj.GetObjectKind().GroupVersionKind()
+	normalizers := append(append([]Normalizer{}, n[AnyKind]...), n[objGVK.GroupKind()]...)
+	if len(normalizers) == 0 {
+		return obj
+	}
+	// the content of unstructured objects is not copied on conversion
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		logrus.Debugf("unable to normalize %s: %s", objGVK.Kind, err)
+		return obj
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(objGVK)
+	for _, normalize := range normalizers {
+		if err := normalize(u); err != nil {
+			logrus.Debugf("unable to normalize %s %s/%s: %s", objGVK.Kind, u.GetNamespace(), u.GetName(), err)
+			return obj
+		}
+	}
+	return u
+}
+
+// podSpecPaths are the paths to the pod specs of core kinds that contain pods
+var podSpecPaths = map[schema.GroupKind][]string{
+	{Kind: "Pod"}:                        {"spec"},
+	{Kind: "ReplicationController"}:      {"spec", "template", "spec"},
+	{Group: "apps", Kind: "Deployment"}:  {"spec", "template", "spec"},
+	{Group: "apps", Kind: "StatefulSet"}: {"spec", "template", "spec"},
+	{Group: "apps", Kind: "DaemonSet"}:   {"spec", "template", "spec"},
+	{Group: "apps", Kind: "ReplicaSet"}:  {"spec", "template", "spec"},
+	{Group: "batch", Kind: "Job"}:        {"spec", "template", "spec"},
+	{Group: "batch", Kind: "CronJob"}:    {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// dropNulls removes fields that are explicitly set to null, which the API server treats as unset
+func dropNulls(obj *unstructured.Unstructured) error {
+	dropNullValues(obj.Object)
+	return nil
+}
+
+// dropNullValues recursively removes keys whose values are null from a map
+func dropNullValues(m map[string]interface{}) {
+	for k, v := range m {
+		switch value := v.(type) {
+		case nil:
+			delete(m, k)
+		case map[string]interface{}:
+			dropNullValues(value)
+		case []interface{}:
+			for _, item := range value {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					dropNullValues(itemMap)
+				}
+			}
+		}
+	}
+}
+
+// dropEmpty removes the provided fields from a map if they are empty strings or lists, leaving them to be defaulted by
+// the API server
+func dropEmpty(m map[string]interface{}, fields ...string) {
+	for _, field := range fields {
+		switch value := m[field].(type) {
+		case string:
+			if len(value) == 0 {
+				delete(m, field)
+			}
+		case []interface{}:
+			if len(value) == 0 {
+				delete(m, field)
+			}
+		}
+	}
+}
+
+// normalizeService drops fields of a Service that are left empty to be allocated or defaulted by the API server
+func normalizeService(obj *unstructured.Unstructured) error {
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	dropEmpty(spec, "type", "clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy", "sessionAffinity", "externalTrafficPolicy", "internalTrafficPolicy")
+	ports, _ := spec["ports"].([]interface{})
+	for _, port := range ports {
+		if port, ok := port.(map[string]interface{}); ok {
+			dropEmpty(port, "protocol")
+		}
+	}
+	return nil
+}
+
+// normalizeSecret merges the stringData of a Secret into its base64-encoded data, as the API server does on storing it
+func normalizeSecret(obj *unstructured.Unstructured) error {
+	dropEmpty(obj.Object, "type")
+	stringData, ok := obj.Object["stringData"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	data, _ := obj.Object["data"].(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	for k, v := range stringData {
+		value, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("stringData.%s is not a string", k)
+		}
+		// stringData takes precedence over data
+		data[k] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	obj.Object["data"] = data
+	delete(obj.Object, "stringData")
+	return nil
+}
+
+// normalizePodSpec returns a Normalizer that normalizes the pod spec at the provided path
+func normalizePodSpec(path ...string) Normalizer {
+	return func(obj *unstructured.Unstructured) error {
+		podSpec, ok, err := unstructured.NestedFieldNoCopy(obj.Object, path...)
+		if err != nil || !ok {
+			return err
+		}
+		spec, ok := podSpec.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		dropEmpty(spec, "restartPolicy", "dnsPolicy", "schedulerName")
+		for _, containersField := range []string{"initContainers", "containers"} {
+			containers, _ := spec[containersField].([]interface{})
+			for _, container := range containers {
+				container, ok := container.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				dropEmpty(container, "imagePullPolicy", "terminationMessagePath", "terminationMessagePolicy")
+				ports, _ := container["ports"].([]interface{})
+				for _, port := range ports {
+					if port, ok := port.(map[string]interface{}); ok {
+						dropEmpty(port, "protocol")
+					}
+				}
+				for _, field := range []string{"limits", "requests"} {
+					if err := canonicalizeQuantities(container, "resources", field); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// normalizeLimitRange formats the quantities of the limits of a LimitRange
+func normalizeLimitRange(obj *unstructured.Unstructured) error {
+	limits, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "limits")
+	items, _ := limits.([]interface{})
+	for _, item := range items {
+		limit, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"max", "min", "default", "defaultRequest", "maxLimitRequestRatio"} {
+			if err := canonicalizeQuantities(limit, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeQuantities returns a Normalizer that formats the quantities in the map at the provided path
+func normalizeQuantities(path ...string) Normalizer {
+	return func(obj *unstructured.Unstructured) error {
+		return canonicalizeQuantities(obj.Object, path...)
+	}
+}
+
+// canonicalizeQuantities formats the quantities in the map at the provided path of m in their canonical form
+// (e.g. 1000m as 1 and 0.5 as 500m), as the API server does on storing them
+func canonicalizeQuantities(m map[string]interface{}, path ...string) error {
+	value, ok, err := unstructured.NestedFieldNoCopy(m, path...)
+	if err != nil || !ok {
+		return err
+	}
+	quantities, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for name, v := range quantities {
+		var s string
+		switch q := v.(type) {
+		case string:
+			s = q
+		case int64:
+			s = strconv.FormatInt(q, 10)
+		case float64:
+			s = strconv.FormatFloat(q, 'f', -1, 64)
+		default:
+			continue
+		}
+		quantity, err := resource.ParseQuantity(s)
+		if err != nil {
+			return fmt.Errorf("invalid quantity %s for %s: %w", s, name, err)
+		}
+		quantities[name] = quantity.String()
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package objectset
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newUnstructured returns an unstructured object with the provided content
+func newUnstructured(content map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestNormalizeObject(t *testing.T) {
+	testCases := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		expected map[string]interface{}
+	}{
+		{
+			name: "persistent volume claim quantities",
+			obj: newUnstructured(map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolumeClaim",
+				"spec": map[string]interface{}{
+					"resources": map[string]interface{}{
+						"requests": map[string]interface{}{"storage": "1024Mi"},
+						"limits":   map[string]interface{}{"storage": int64(1)},
+					},
+				},
+			}),
+			expected: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolumeClaim",
+				"spec": map[string]interface{}{
+					"resources": map[string]interface{}{
+						"requests": map[string]interface{}{"storage": "1Gi"},
+						"limits":   map[string]interface{}{"storage": "1"},
+					},
+				},
+			},
+		},
+		{
+			name: "container quantities and defaults",
+			obj: newUnstructured(map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"dnsPolicy": "",
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name":            "app",
+									"imagePullPolicy": "",
+									"resources": map[string]interface{}{
+										"requests": map[string]interface{}{"cpu": "1000m", "memory": 0.5},
+									},
+								},
+							},
+						},
+					},
+				},
+			}),
+			expected: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name": "app",
+									"resources": map[string]interface{}{
+										"requests": map[string]interface{}{"cpu": "1", "memory": "500m"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "secret string data",
+			obj: newUnstructured(map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"type":       "",
+				"data": map[string]interface{}{
+					"overridden": base64.StdEncoding.EncodeToString([]byte("data")),
+					"kept":       base64.StdEncoding.EncodeToString([]byte("kept")),
+				},
+				"stringData": map[string]interface{}{
+					"overridden": "stringData",
+					"added":      "added",
+				},
+			}),
+			expected: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"data": map[string]interface{}{
+					"overridden": base64.StdEncoding.EncodeToString([]byte("stringData")),
+					"kept":       base64.StdEncoding.EncodeToString([]byte("kept")),
+					"added":      base64.StdEncoding.EncodeToString([]byte("added")),
+				},
+			},
+		},
+		{
+			name: "nulls inside lists",
+			obj: newUnstructured(map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Example",
+				"metadata": map[string]interface{}{
+					"annotations": nil,
+				},
+				"spec": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{
+							"name":  "a",
+							"value": nil,
+							"nested": map[string]interface{}{
+								"value": nil,
+							},
+						},
+						"b",
+					},
+				},
+			}),
+			expected: map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Example",
+				"metadata":   map[string]interface{}{},
+				"spec": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{
+							"name":   "a",
+							"nested": map[string]interface{}{},
+						},
+						"b",
+					},
+				},
+			},
+		},
+		{
+			name: "invalid quantity",
+			obj: newUnstructured(map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ResourceQuota",
+				"spec": map[string]interface{}{
+					"hard": map[string]interface{}{"pods": "many", "value": nil},
+				},
+			}),
+			// objects that cannot be normalized are left as they are
+			expected: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ResourceQuota",
+				"spec": map[string]interface{}{
+					"hard": map[string]interface{}{"pods": "many", "value": nil},
+				},
+			},
+		},
+	}
+	normalizers := DefaultNormalizers()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := tc.obj.DeepCopy()
+			normalized := normalizers.normalizeObject(tc.obj)
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(normalized)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(content, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, content)
+			}
+			if !reflect.DeepEqual(tc.obj, original) {
+				t.Errorf("expected the provided object to not be modified, got %v", tc.obj)
+			}
+		})
+	}
+}
+
+func TestNormalizersDivergentPaths(t *testing.T) {
+	desired := newUnstructured(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"stringData": map[string]interface{}{"password": "secret"},
+	})
+	live := newUnstructured(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"data":       map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("secret"))},
+	})
+	normalizers := DefaultNormalizers()
+
+	paths, err := normalizers.divergentPaths(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("expected differences caused by the API server to be ignored, got %v", paths)
+	}
+
+	live.Object["data"] = map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("changed"))}
+	paths, err = normalizers.divergentPaths(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(paths, []string{"data.password"}) {
+		t.Errorf("expected changed data to diverge, got %v", paths)
+	}
+	if _, ok := desired.Object["stringData"]; !ok {
+		t.Errorf("expected the desired object to be applied as is, got %v", desired.Object)
+	}
+}
@@ -0,0 +1,64 @@
+package objectset
+
+import "fmt"
+
+// ApplyMode determines how the objects tracked by an ObjectSet are applied onto the cluster
+type ApplyMode string
+
+const (
+	// ClientSideApplyMode applies objects with client-side three-way merge patches
+	ClientSideApplyMode ApplyMode = "client-side"
+
+	// ServerSideApplyMode applies objects with Kubernetes server-side apply, so only fields in the ObjectSet are owned and reverted
+	ServerSideApplyMode ApplyMode = "server-side"
+
+	// DefaultApplyMode is the ApplyMode used if none is provided
+	DefaultApplyMode = ClientSideApplyMode
+
+	// DefaultFieldManager is the field manager used to apply objects with server-side apply if none is provided
+	DefaultFieldManager = "helm-locker"
+)
+
+// Validate returns an error if the ApplyMode is not supported
+func (m ApplyMode) Validate() error {
+	switch m {
+	case ClientSideApplyMode, ServerSideApplyMode:
+		return nil
+	default:
+		return fmt.Errorf("invalid apply mode %q: must be one of %s or %s", m, ClientSideApplyMode, ServerSideApplyMode)
+	}
+}
+
+// LockOptions configure how ObjectSets registered on a LockableRegister are locked
+type LockOptions struct {
+	// ConflictPolicy determines which ObjectSet locks an object that is tracked by more than one ObjectSet
+	ConflictPolicy ConflictPolicy
+	// FlapDetection determines when objects that keep being modified by another actor are no longer reverted
+	FlapDetection FlapDetection
+	// ApplyMode determines how the objects tracked by an ObjectSet are applied onto the cluster
+	ApplyMode ApplyMode
+	// FieldManager is the field manager used to apply objects with server-side apply
+	FieldManager string
+	// Recreate determines which objects are deleted and re-created if reverting changes to them fails
+	Recreate Recreate
+	// Normalizers rewrite copies of objects into the form that the API server stores them in on comparing them, so that
+	// differences caused by the API server are not considered drift; DefaultNormalizers are used if not provided
+	Normalizers Normalizers
+}
+
+// applyDefaults returns the LockOptions with defaults set for any options that are not provided
+func (o LockOptions) applyDefaults() LockOptions {
+	if len(o.ConflictPolicy) == 0 {
+		o.ConflictPolicy = DefaultConflictPolicy
+	}
+	if len(o.ApplyMode) == 0 {
+		o.ApplyMode = DefaultApplyMode
+	}
+	if len(o.FieldManager) == 0 {
+		o.FieldManager = DefaultFieldManager
+	}
+	if o.Normalizers == nil {
+		o.Normalizers = DefaultNormalizers()
+	}
+	return o
+}
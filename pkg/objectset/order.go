@@ -0,0 +1,115 @@
+package objectset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// crdEstablishedPollInterval is how often CustomResourceDefinitions are checked for being established
+	crdEstablishedPollInterval = time.Second
+
+	// crdEstablishedTimeout is how long to wait for CustomResourceDefinitions to be established before applying custom resources
+	crdEstablishedTimeout = time.Minute
+)
+
+// crdGVK is the GroupVersionKind of CustomResourceDefinitions
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// sortGVKs returns the GVKs sorted by the provided Helm kind sort order
+//
+// Kinds that are not in the order are sorted alphabetically after all known kinds, as Helm does
+func sortGVKs(gvks []schema.GroupVersionKind, order releaseutil.KindSortOrder) []schema.GroupVersionKind {
+	ordering := make(map[string]int, len(order))
+	for i, kind := range order {
+		ordering[kind] = i
+	}
+	sorted := append([]schema.GroupVersionKind{}, gvks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		first, iok := ordering[sorted[i].Kind]
+		second, jok := ordering[sorted[j].Kind]
+		switch {
+		case iok && jok && first != second:
+			return first < second
+		case iok != jok:
+			// unknown kinds are last
+			return iok
+		case sorted[i].Kind != sorted[j].Kind:
+			return sorted[i].Kind < sorted[j].Kind
+		default:
+			return sorted[i].String() < sorted[j].String()
+		}
+	})
+	return sorted
+}
+
+// objectsOfGVK returns an ObjectSet with the objects of an ObjectSet that have the provided GVK
+func objectsOfGVK(os *objectset.ObjectSet, gvk schema.GroupVersionKind) *objectset.ObjectSet {
+	stage := objectset.NewObjectSet()
+	for _, obj := range os.ObjectsByGVK()[gvk] {
+		stage.Add(obj)
+	}
+	return stage
+}
+
+// waitForCRDs waits until the CustomResourceDefinitions in crds that define kinds of objects in the ObjectSet are established
+func (h *handler) waitForCRDs(crds []runtime.Object, os *objectset.ObjectSet) error {
+	groupKinds := map[schema.GroupKind]bool{}
+	for _, gvk := range os.GVKs() {
+		groupKinds[gvk.GroupKind()] = true
+	}
+	c, err := h.clientFactory.ForKind(crdGVK)
+	if err != nil {
+		return err
+	}
+	for _, crd := range crds {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+		if err != nil {
+			return err
+		}
+		u := &unstructured.Unstructured{Object: content}
+		group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+		if !groupKinds[schema.GroupKind{Group: group, Kind: kind}] {
+			// no objects in the ObjectSet depend on this CustomResourceDefinition
+			continue
+		}
+		logrus.Debugf("waiting for CustomResourceDefinition %s to be established", u.GetName())
+		if err := wait.PollUntilContextTimeout(context.TODO(), crdEstablishedPollInterval, crdEstablishedTimeout, true, func(ctx context.Context) (bool, error) {
+			live := &unstructured.Unstructured{}
+			if err := c.Get(ctx, "", u.GetName(), live, metav1.GetOptions{}); err != nil {
+				return false, err
+			}
+			return established(live), nil
+		}); err != nil {
+			return fmt.Errorf("failed waiting for CustomResourceDefinition %s to be established: %w", u.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// established returns whether a CustomResourceDefinition has the Established condition set to True
+func established(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package objectset
+
+import (
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSortGVKs(t *testing.T) {
+	var (
+		namespace  = schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+		crd        = crdGVK
+		configMap  = configMapGVK
+		deployment = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+		service    = schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+		fooV1      = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"}
+		fooV2      = schema.GroupVersionKind{Group: "example.com", Version: "v2", Kind: "Foo"}
+		bar        = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Bar"}
+	)
+	gvks := []schema.GroupVersionKind{fooV2, deployment, bar, service, configMap, fooV1, crd, namespace}
+	testCases := []struct {
+		name     string
+		order    releaseutil.KindSortOrder
+		expected []schema.GroupVersionKind
+	}{
+		{
+			name:     "install order",
+			order:    releaseutil.InstallOrder,
+			expected: []schema.GroupVersionKind{namespace, configMap, crd, service, deployment, bar, fooV1, fooV2},
+		},
+		{
+			name:     "uninstall order",
+			order:    releaseutil.UninstallOrder,
+			expected: []schema.GroupVersionKind{service, deployment, crd, configMap, namespace, bar, fooV1, fooV2},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := append([]schema.GroupVersionKind{}, gvks...)
+			sorted := sortGVKs(gvks, tc.order)
+			if !reflect.DeepEqual(sorted, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, sorted)
+			}
+			if !reflect.DeepEqual(gvks, original) {
+				t.Errorf("expected the provided GVKs to not be modified, got %v", gvks)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// APIVersionMappings map the apiVersion and kind of objects that may no longer be served by the cluster to the
+// apiVersion that they are rewritten to
+type APIVersionMappings map[schema.GroupVersionKind]schema.GroupVersion
+
+// DefaultAPIVersionMappings returns mappings for the apiVersions removed from Kubernetes whose objects can be served by
+// a newer apiVersion of the same kind
+func DefaultAPIVersionMappings() APIVersionMappings {
+	appsV1 := schema.GroupVersion{Group: "apps", Version: "v1"}
+	networkingV1 := schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}
+	rbacV1 := schema.GroupVersion{Group: "rbac.authorization.k8s.io", Version: "v1"}
+	storageV1 := schema.GroupVersion{Group: "storage.k8s.io", Version: "v1"}
+	return APIVersionMappings{
+		{Group: "extensions", Version: "v1beta1", Kind: "Deployment"}: appsV1,
+		{Group: "extensions", Version: "v1beta1", Kind: "DaemonSet"}:  appsV1,
+		{Group: "extensions", Version: "v1beta1", Kind: "ReplicaSet"}: appsV1,
+		{Group: "apps", Version: "v1beta1", Kind: "Deployment"}:       appsV1,
+		{Group: "apps", Version: "v1beta1", Kind: "StatefulSet"}:      appsV1,
+		{Group: "apps", Version: "v1beta2", Kind: "Deployment"}:       appsV1,
+		{Group: "apps", Version: "v1beta2", Kind: "DaemonSet"}:        appsV1,
+		{Group: "apps", Version: "v1beta2", Kind: "ReplicaSet"}:       appsV1,
+		{Group: "apps", Version: "v1beta2", Kind: "StatefulSet"}:      appsV1,
+
+		{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}:             networkingV1,
+		{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy"}:       networkingV1,
+		{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}:      networkingV1,
+		{Group: "networking.k8s.io", Version: "v1beta1", Kind: "IngressClass"}: networkingV1,
+
+		{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}:                   {Group: "policy", Version: "v1"},
+		{Group: "batch", Version: "v1beta1", Kind: "CronJob"}:                                {Group: "batch", Version: "v1"},
+		{Group: "autoscaling", Version: "v2beta2", Kind: "HorizontalPodAutoscaler"}:          {Group: "autoscaling", Version: "v2"},
+		{Group: "scheduling.k8s.io", Version: "v1beta1", Kind: "PriorityClass"}:              {Group: "scheduling.k8s.io", Version: "v1"},
+		{Group: "coordination.k8s.io", Version: "v1beta1", Kind: "Lease"}:                    {Group: "coordination.k8s.io", Version: "v1"},
+		{Group: "node.k8s.io", Version: "v1beta1", Kind: "RuntimeClass"}:                     {Group: "node.k8s.io", Version: "v1"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Kind: "ClusterRole"}:        rbacV1,
+		{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Kind: "ClusterRoleBinding"}: rbacV1,
+		{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Kind: "Role"}:               rbacV1,
+		{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Kind: "RoleBinding"}:        rbacV1,
+		{Group: "storage.k8s.io", Version: "v1beta1", Kind: "StorageClass"}:                  storageV1,
+		{Group: "storage.k8s.io", Version: "v1beta1", Kind: "CSIDriver"}:                     storageV1,
+		{Group: "storage.k8s.io", Version: "v1beta1", Kind: "CSINode"}:                       storageV1,
+	}
+}
+
+// ParseAPIVersionMappings parses mappings provided as Kind.version.group=group/version
+// (e.g. Ingress.v1beta1.extensions=networking.k8s.io/v1) on top of the DefaultAPIVersionMappings
+func ParseAPIVersionMappings(mappings []string) (APIVersionMappings, error) {
+	parsed := DefaultAPIVersionMappings()
+	for _, mapping := range mappings {
+		from, to, ok := strings.Cut(mapping, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid apiVersion mapping %s: expected Kind.version.group=group/version", mapping)
+		}
+		fromGVK, _ := schema.ParseKindArg(from)
+		if fromGVK == nil || len(fromGVK.Kind) == 0 {
+			return nil, fmt.Errorf("invalid apiVersion mapping %s: %s is not provided as Kind.version.group", mapping, from)
+		}
+		toGV, err := schema.ParseGroupVersion(to)
+		if err != nil || toGV.Empty() {
+			return nil, fmt.Errorf("invalid apiVersion mapping %s: %s is not a valid apiVersion", mapping, to)
+		}
+		parsed[*fromGVK] = toGV
+	}
+	return parsed, nil
+}
+
+// UnservedError is an error reported for an object whose apiVersion is not served by the cluster and cannot be mapped
+// to an apiVersion that is
+type UnservedError struct {
+	// APIVersion is the apiVersion of the object in the manifest
+	APIVersion string
+	// Kind is the kind of the object
+	Kind string
+	// Namespace is the namespace of the object, if set in the manifest
+	Namespace string
+	// Name is the name of the object
+	Name string
+	// Source is the template that rendered the object, if known
+	Source string
+}
+
+// Error returns a description of the UnservedError
+func (e *UnservedError) Error() string {
+	name := e.Name
+	if len(e.Namespace) > 0 {
+		name = e.Namespace + "/" + e.Name
+	}
+	if len(e.Source) == 0 {
+		return fmt.Sprintf("%s %s: apiVersion %s is not served by the cluster", e.Kind, name, e.APIVersion)
+	}
+	return fmt.Sprintf("%s %s (%s): apiVersion %s is not served by the cluster", e.Kind, name, e.Source, e.APIVersion)
+}
+
+// mapAPIVersions rewrites objects whose apiVersion is not served by the cluster to the apiVersion that they are mapped
+// to, returning the objects that are served along with their sources
+//
+// Objects that are not served and have no served mapping are dropped and reported as an UnservedError. Kinds defined
+// by CustomResourceDefinitions in the same manifest are always considered served since they may not be installed yet,
+// and objects whose apiVersion cannot be looked up for other reasons are left alone. If no RESTMapper is provided,
+// all objects are left alone and an error is returned.
+func mapAPIVersions(objs []*unstructured.Unstructured, sources []string, mappings APIVersionMappings, mapper meta.RESTMapper) ([]*unstructured.Unstructured, []string, error) {
+	if mapper == nil {
+		return objs, sources, errors.New("unable to map apiVersions: a RESTMapper must be provided to identify served apiVersions")
+	}
+	var multierr error
+	defined := crdScopes(objs)
+	var served []*unstructured.Unstructured
+	var servedSources []string
+	for i, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		if _, ok := defined[gvk.GroupKind()]; ok || isServed(mapper, gvk) {
+			served = append(served, obj)
+			servedSources = append(servedSources, sources[i])
+			continue
+		}
+		to, ok := mappings[gvk]
+		if ok && isServed(mapper, to.WithKind(gvk.Kind)) {
+			if err := convert(obj, to); err != nil {
+				multierr = errors.Join(multierr, fmt.Errorf("unable to map %s %s to %s: %w", gvk.Kind, obj.GetName(), to, err))
+				continue
+			}
+			logrus.Infof("mapped %s %s from unserved apiVersion %s to %s", gvk.Kind, obj.GetName(), gvk.GroupVersion(), to)
+			served = append(served, obj)
+			servedSources = append(servedSources, sources[i])
+			continue
+		}
+		multierr = errors.Join(multierr, &UnservedError{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			Source:     sources[i],
+		})
+	}
+	return served, servedSources, multierr
+}
+
+// isServed returns whether the cluster serves the provided GroupVersionKind, assuming it does if that cannot be identified
+func isServed(mapper meta.RESTMapper, gvk schema.GroupVersionKind) bool {
+	_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil && !meta.IsNoMatchError(err) {
+		logrus.Debugf("unable to identify whether %s is served, assuming it is: %s", gvk, err)
+		return true
+	}
+	return err == nil
+}
+
+// convert rewrites an object to the provided apiVersion, converting fields whose schema changed between apiVersions
+func convert(obj *unstructured.Unstructured, to schema.GroupVersion) error {
+	if obj.GetKind() == "Ingress" && to == (schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}) {
+		if err := convertIngress(obj); err != nil {
+			return err
+		}
+	}
+	if to == (schema.GroupVersion{Group: "apps", Version: "v1"}) {
+		switch obj.GetKind() {
+		case "Deployment", "DaemonSet", "ReplicaSet", "StatefulSet":
+			if err := convertWorkload(obj); err != nil {
+				return err
+			}
+		}
+	}
+	obj.SetAPIVersion(to.String())
+	return nil
+}
+
+// convertWorkload converts the fields of an extensions/v1beta1, apps/v1beta1, or apps/v1beta2 workload to those of an
+// apps/v1 workload, which requires a selector rather than defaulting it to the labels of the pod template
+func convertWorkload(obj *unstructured.Unstructured) error {
+	if _, ok, err := unstructured.NestedFieldNoCopy(obj.Object, "spec", "selector"); err != nil || ok {
+		return err
+	}
+	labels, ok, err := unstructured.NestedMap(obj.Object, "spec", "template", "metadata", "labels")
+	if err != nil || !ok {
+		return err
+	}
+	return unstructured.SetNestedMap(obj.Object, labels, "spec", "selector", "matchLabels")
+}
+
+// convertIngress converts the fields of an extensions/v1beta1 or networking.k8s.io/v1beta1 Ingress to those of a
+// networking.k8s.io/v1 Ingress
+func convertIngress(obj *unstructured.Unstructured) error {
+	spec, ok, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !ok {
+		return err
+	}
+	if backend, ok := spec["backend"].(map[string]interface{}); ok {
+		spec["defaultBackend"] = convertIngressBackend(backend)
+		delete(spec, "backend")
+	}
+	rules, _ := spec["rules"].([]interface{})
+	for _, rule := range rules {
+		rule, _ := rule.(map[string]interface{})
+		http, _ := rule["http"].(map[string]interface{})
+		paths, _ := http["paths"].([]interface{})
+		for _, path := range paths {
+			path, ok := path.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if backend, ok := path["backend"].(map[string]interface{}); ok {
+				path["backend"] = convertIngressBackend(backend)
+			}
+			if _, ok := path["pathType"]; !ok {
+				// the default pathType of the removed apiVersions
+				path["pathType"] = "ImplementationSpecific"
+			}
+		}
+	}
+	return unstructured.SetNestedMap(obj.Object, spec, "spec")
+}
+
+// convertIngressBackend converts a v1beta1 IngressBackend (serviceName and servicePort) to a v1 IngressBackend
+func convertIngressBackend(backend map[string]interface{}) map[string]interface{} {
+	serviceName, ok := backend["serviceName"]
+	if !ok {
+		// a resource backend, which is unchanged
+		return backend
+	}
+	port := map[string]interface{}{}
+	switch servicePort := backend["servicePort"].(type) {
+	case string:
+		port["name"] = servicePort
+	case nil:
+	default:
+		port["number"] = servicePort
+	}
+	return map[string]interface{}{
+		"service": map[string]interface{}{
+			"name": serviceName,
+			"port": port,
+		},
+	}
+}
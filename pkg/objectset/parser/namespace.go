@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"github.com/sirupsen/logrus"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// setNamespaces sets the namespace on namespaced objects that do not specify a namespace, as Helm does on installing a release
+//
+// Cluster-scoped objects and objects whose scope cannot be identified are left alone. Custom resources defined by
+// CustomResourceDefinitions in the same manifest are identified by the scope of the CustomResourceDefinition,
+// since it may not be installed yet.
+func setNamespaces(objs []*unstructured.Unstructured, namespace string, mapper meta.RESTMapper) {
+	scopes := crdScopes(objs)
+	for _, obj := range objs {
+		if len(obj.GetNamespace()) > 0 {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		namespaced, ok := scopes[gvk.GroupKind()]
+		if !ok {
+			if mapper == nil {
+				continue
+			}
+			mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				logrus.Debugf("unable to identify scope of %s, leaving namespace of %s unset: %s", gvk, obj.GetName(), err)
+				continue
+			}
+			namespaced = mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		}
+		if namespaced {
+			obj.SetNamespace(namespace)
+		}
+	}
+}
+
+// crdScopes returns whether the kinds defined by the CustomResourceDefinitions in objs are namespaced
+func crdScopes(objs []*unstructured.Unstructured) map[schema.GroupKind]bool {
+	scopes := map[schema.GroupKind]bool{}
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind() != apiextv1.Kind("CustomResourceDefinition") {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+		scopes[schema.GroupKind{Group: group, Kind: kind}] = scope == string(apiextv1.NamespaceScoped)
+	}
+	return scopes
+}
@@ -1,33 +1,171 @@
 package parser
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 
+	lockerobjectset "github.com/rancher/helm-locker/pkg/objectset"
 	"github.com/rancher/wrangler/v3/pkg/objectset"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
-// Parse parses the runtime.Objects tracked in a Kubernetes manifest (represented as a string) into an ObjectSet
-func Parse(manifest string) (*objectset.ObjectSet, error) {
+const (
+	// sourcePrefix is the prefix of the comment that Helm adds to each document of a rendered manifest to identify its template
+	sourcePrefix = "# Source: "
+)
+
+// Options configure how a Kubernetes manifest is parsed
+type Options struct {
+	// Namespace is the namespace set on namespaced objects that do not specify a namespace, if provided
+	Namespace string
+	// RESTMapper identifies whether objects are namespaced and served; it must be provided if Namespace or APIVersionMappings are provided
+	RESTMapper meta.RESTMapper
+	// APIVersionMappings rewrite objects whose apiVersion is not served by the cluster to an apiVersion that is, if provided
+	APIVersionMappings APIVersionMappings
+}
+
+// DocumentError is an error encountered on parsing a single document of a Kubernetes manifest
+type DocumentError struct {
+	// Index is the index of the document in the manifest, starting from 0
+	Index int
+	// Source is the template that rendered the document, if known
+	Source string
+	// Err is the error encountered on parsing the document
+	Err error
+}
+
+// Error returns a description of the DocumentError
+func (e *DocumentError) Error() string {
+	if len(e.Source) == 0 {
+		return fmt.Sprintf("document %d: %s", e.Index, e.Err)
+	}
+	return fmt.Sprintf("document %d (%s): %s", e.Index, e.Source, e.Err)
+}
+
+// Unwrap returns the error encountered on parsing the document
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// Parse parses the runtime.Objects tracked in a Kubernetes manifest (represented as a string) into an ObjectSet along
+// with the Sources of the objects, identified by the "# Source:" comments that Helm adds to each document
+//
+// Documents that cannot be parsed are skipped and reported as a DocumentError in the returned error, so the
+// ObjectSet contains every object that could be parsed. Similarly, if APIVersionMappings are provided, objects whose
+// apiVersion is not served by the cluster and cannot be mapped to one that is are skipped and reported as an UnservedError.
+func Parse(manifest string, opts Options) (*objectset.ObjectSet, lockerobjectset.Sources, error) {
 	var multierr error
 
-	var u unstructured.Unstructured
-	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(manifest)), 1000)
-	os := objectset.NewObjectSet()
-	for {
-		uCopy := u.DeepCopy()
-		err := decoder.Decode(uCopy)
+	var objs []*unstructured.Unstructured
+	var sources []string
+	reader := yaml.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+	for i := 0; ; i++ {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
+			// the manifest cannot be split into any more documents
+			multierr = errors.Join(multierr, &DocumentError{Index: i, Err: err})
 			break
 		}
-		if uCopy.GetAPIVersion() == "" || uCopy.GetKind() == "" {
-			// Encountered empty YAML document but successfully decoded, skip
+		docSource := source(doc)
+		docObjs, err := decode(doc)
+		if err != nil {
+			multierr = errors.Join(multierr, &DocumentError{Index: i, Source: docSource, Err: err})
 			continue
 		}
-		os = os.Add(uCopy)
-		logrus.Debugf("obj: %s, Kind=%s (%s/%s)", uCopy.GetAPIVersion(), uCopy.GetKind(), uCopy.GetName(), uCopy.GetNamespace())
+		for range docObjs {
+			sources = append(sources, docSource)
+		}
+		objs = append(objs, docObjs...)
+	}
+	if opts.APIVersionMappings != nil {
+		var err error
+		objs, sources, err = mapAPIVersions(objs, sources, opts.APIVersionMappings, opts.RESTMapper)
+		multierr = errors.Join(multierr, err)
+	}
+	if len(opts.Namespace) > 0 {
+		setNamespaces(objs, opts.Namespace, opts.RESTMapper)
+	}
+	os := objectset.NewObjectSet()
+	objSources := lockerobjectset.Sources{}
+	for i, obj := range objs {
+		os = os.Add(obj)
+		if len(sources[i]) > 0 {
+			if err := objSources.Add(obj, sources[i]); err != nil {
+				multierr = errors.Join(multierr, err)
+			}
+		}
+		logrus.Debugf("obj: %s, Kind=%s (%s/%s) from %s", obj.GetAPIVersion(), obj.GetKind(), obj.GetName(), obj.GetNamespace(), sources[i])
+	}
+	return os, objSources, multierr
+}
+
+// decode decodes a single YAML or JSON document into the objects it contains
+//
+// Documents that contain a List (e.g. kind: List or kind: ConfigMapList) are expanded into the items of the List and
+// empty documents contain no objects
+func decode(doc []byte) ([]*unstructured.Unstructured, error) {
+	content := map[string]interface{}{}
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(doc), len(doc)+1).Decode(&content); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return expand(&unstructured.Unstructured{Object: content})
+}
+
+// expand returns the items of an object if it is a List, or the object itself otherwise
+func expand(obj *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+		return nil, fmt.Errorf("object is missing apiVersion or kind")
+	}
+	if !obj.IsList() {
+		return []*unstructured.Unstructured{obj}, nil
+	}
+	list, err := obj.ToList()
+	if err != nil {
+		return nil, fmt.Errorf("unable to expand %s: %w", obj.GetKind(), err)
+	}
+	var objs []*unstructured.Unstructured
+	for i := range list.Items {
+		item := &list.Items[i]
+		if itemKind := strings.TrimSuffix(obj.GetKind(), "List"); len(itemKind) > 0 {
+			// items of typed lists (e.g. ConfigMapList) may omit their apiVersion and kind
+			if item.GetAPIVersion() == "" {
+				item.SetAPIVersion(obj.GetAPIVersion())
+			}
+			if item.GetKind() == "" {
+				item.SetKind(itemKind)
+			}
+		}
+		items, err := expand(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d of %s: %w", i, obj.GetKind(), err)
+		}
+		objs = append(objs, items...)
+	}
+	return objs, nil
+}
+
+// source returns the template that rendered a document of a manifest rendered by Helm, if known
+func source(doc []byte) string {
+	for _, line := range strings.Split(string(doc), "\n") {
+		if strings.HasPrefix(line, sourcePrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, sourcePrefix))
+		}
 	}
-	return os, multierr
+	return ""
 }
@@ -0,0 +1,474 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	configMapGVK   = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	clusterRoleGVK = schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	ingressGVK     = schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
+)
+
+// newTestRESTMapper returns a RESTMapper that serves namespaced ConfigMaps and cluster-scoped ClusterRoles along with
+// the provided GVKs, which are namespaced
+func newTestRESTMapper(gvks ...schema.GroupVersionKind) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(configMapGVK, meta.RESTScopeNamespace)
+	mapper.Add(clusterRoleGVK, meta.RESTScopeRoot)
+	for _, gvk := range gvks {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return mapper
+}
+
+// getObject returns the object of the ObjectSet with the provided GVK, namespace, and name
+func getObject(t *testing.T, os *objectset.ObjectSet, gvk schema.GroupVersionKind, namespace, name string) *unstructured.Unstructured {
+	t.Helper()
+	obj, ok := os.ObjectsByGVK()[gvk][objectset.ObjectKey{Namespace: namespace, Name: name}]
+	if !ok {
+		t.Fatalf("expected objectset to contain %s %s/%s, got %v", gvk.Kind, namespace, name, os.ObjectsByGVK())
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		t.Fatalf("expected %s %s/%s to be unstructured, got %T", gvk.Kind, namespace, name, obj)
+	}
+	return u
+}
+
+func TestParseNamespaces(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaulted
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: explicit
+  namespace: other
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: cluster-scoped
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: unknown
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  scope: Namespaced
+  names:
+    kind: Foo
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: bars.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Bar
+---
+apiVersion: example.com/v1
+kind: Foo
+metadata:
+  name: namespaced-custom-resource
+---
+apiVersion: example.com/v1
+kind: Bar
+metadata:
+  name: cluster-scoped-custom-resource
+`
+	os, _, err := Parse(manifest, Options{Namespace: "release", RESTMapper: newTestRESTMapper()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getObject(t, os, configMapGVK, "release", "defaulted")
+	getObject(t, os, configMapGVK, "other", "explicit")
+	getObject(t, os, clusterRoleGVK, "", "cluster-scoped")
+	// objects whose scope cannot be identified are left alone
+	getObject(t, os, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Unknown"}, "", "unknown")
+	getObject(t, os, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"}, "release", "namespaced-custom-resource")
+	getObject(t, os, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Bar"}, "", "cluster-scoped-custom-resource")
+
+	// namespaces are only set if a namespace is provided
+	os, _, err = Parse(manifest, Options{RESTMapper: newTestRESTMapper()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getObject(t, os, configMapGVK, "", "defaulted")
+}
+
+func TestParseDocumentErrors(t *testing.T) {
+	manifest := `---
+# Source: chart/templates/first.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+# Source: chart/templates/malformed.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: malformed
+  labels: [
+---
+# Source: chart/templates/missing-kind.yaml
+apiVersion: v1
+metadata:
+  name: missing-kind
+---
+# Source: chart/templates/last.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: last
+`
+	os, _, err := Parse(manifest, Options{})
+	if err == nil {
+		t.Fatal("expected malformed documents to be reported")
+	}
+	// documents are still parsed after a malformed document
+	getObject(t, os, configMapGVK, "", "first")
+	getObject(t, os, configMapGVK, "", "last")
+	if len(os.All()) != 2 {
+		t.Errorf("expected only the documents that could be parsed to be in the objectset, got %d objects", len(os.All()))
+	}
+
+	var docErrs []*DocumentError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var docErr *DocumentError
+		if !errors.As(e, &docErr) {
+			t.Fatalf("expected a DocumentError, got %v", e)
+		}
+		docErrs = append(docErrs, docErr)
+	}
+	if len(docErrs) != 2 {
+		t.Fatalf("expected a DocumentError for each malformed document, got %v", err)
+	}
+	if docErrs[0].Index != 1 || docErrs[0].Source != "chart/templates/malformed.yaml" {
+		t.Errorf("expected the malformed document to be identified by its index and source, got %q", docErrs[0])
+	}
+	if docErrs[1].Index != 2 || docErrs[1].Source != "chart/templates/missing-kind.yaml" {
+		t.Errorf("expected the document missing a kind to be identified by its index and source, got %q", docErrs[1])
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: list-item
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: list-item
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: nested-list-item
+---
+apiVersion: v1
+kind: ConfigMapList
+items:
+- metadata:
+    name: typed-list-item
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: explicit-typed-list-item
+---
+apiVersion: v1
+kind: List
+items: []
+`
+	os, _, err := Parse(manifest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getObject(t, os, configMapGVK, "", "list-item")
+	getObject(t, os, clusterRoleGVK, "", "list-item")
+	getObject(t, os, configMapGVK, "", "nested-list-item")
+	getObject(t, os, configMapGVK, "", "typed-list-item")
+	getObject(t, os, configMapGVK, "", "explicit-typed-list-item")
+	if len(os.All()) != 5 {
+		t.Errorf("expected lists to be expanded into their items, got %d objects", len(os.All()))
+	}
+
+	// items of untyped lists must specify their apiVersion and kind
+	_, _, err = Parse(`
+apiVersion: v1
+kind: List
+items:
+- metadata:
+    name: missing-kind
+`, Options{})
+	var docErr *DocumentError
+	if !errors.As(err, &docErr) || docErr.Index != 0 {
+		t.Errorf("expected a List item without a kind to be reported, got %v", err)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	manifest := `---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: templated
+---
+# Source: chart/templates/list.yaml
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: list-item
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unknown-source
+`
+	os, sources, err := Parse(manifest, Options{Namespace: "release", RESTMapper: newTestRESTMapper()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "templated", expected: "chart/templates/configmap.yaml"},
+		{name: "list-item", expected: "chart/templates/list.yaml"},
+		{name: "unknown-source", expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// sources are recorded for objects after their namespace is defaulted
+			obj := getObject(t, os, configMapGVK, "release", tc.name)
+			if source := sources.For(obj); source != tc.expected {
+				t.Errorf("expected source %q, got %q", tc.expected, source)
+			}
+		})
+	}
+}
+
+func TestParseAPIVersionMappings(t *testing.T) {
+	manifest := `---
+# Source: chart/templates/ingress.yaml
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: mapped
+spec:
+  backend:
+    serviceName: default
+    servicePort: 80
+  rules:
+  - http:
+      paths:
+      - path: /
+        backend:
+          serviceName: app
+          servicePort: http
+      - path: /exact
+        pathType: Exact
+        backend:
+          resource:
+            apiGroup: example.com
+            kind: Bucket
+            name: static
+---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: served
+---
+# Source: chart/templates/cronjob.yaml
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: unserved
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  scope: Namespaced
+  names:
+    kind: Foo
+---
+apiVersion: example.com/v1
+kind: Foo
+metadata:
+  name: defined-in-manifest
+`
+	os, sources, err := Parse(manifest, Options{
+		RESTMapper:         newTestRESTMapper(ingressGVK),
+		APIVersionMappings: DefaultAPIVersionMappings(),
+	})
+	var unservedErr *UnservedError
+	if !errors.As(err, &unservedErr) {
+		t.Fatalf("expected an UnservedError for the object that cannot be mapped, got %v", err)
+	}
+	expectedErr := &UnservedError{APIVersion: "batch/v1beta1", Kind: "CronJob", Name: "unserved", Source: "chart/templates/cronjob.yaml"}
+	if !reflect.DeepEqual(unservedErr, expectedErr) {
+		t.Errorf("expected %v, got %v", expectedErr, unservedErr)
+	}
+	if len(os.ObjectsByGVK()[schema.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"}]) != 0 {
+		t.Error("expected the object that cannot be mapped to be dropped")
+	}
+	getObject(t, os, configMapGVK, "", "served")
+	getObject(t, os, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"}, "", "defined-in-manifest")
+
+	ingress := getObject(t, os, ingressGVK, "", "mapped")
+	if source := sources.For(ingress); source != "chart/templates/ingress.yaml" {
+		t.Errorf("expected the mapped object to keep its source, got %q", source)
+	}
+	expectedSpec := map[string]interface{}{
+		"defaultBackend": map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": "default",
+				// manifests are decoded as JSON, so numbers are float64
+				"port": map[string]interface{}{"number": float64(80)},
+			},
+		},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"http": map[string]interface{}{
+					"paths": []interface{}{
+						map[string]interface{}{
+							"path":     "/",
+							"pathType": "ImplementationSpecific",
+							"backend": map[string]interface{}{
+								"service": map[string]interface{}{
+									"name": "app",
+									"port": map[string]interface{}{"name": "http"},
+								},
+							},
+						},
+						map[string]interface{}{
+							"path":     "/exact",
+							"pathType": "Exact",
+							"backend": map[string]interface{}{
+								"resource": map[string]interface{}{
+									"apiGroup": "example.com",
+									"kind":     "Bucket",
+									"name":     "static",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(ingress.Object["spec"], expectedSpec) {
+		t.Errorf("expected the Ingress to be converted to %v, got %v", expectedSpec, ingress.Object["spec"])
+	}
+}
+
+func TestParseAPIVersionMappingsWithoutRESTMapper(t *testing.T) {
+	manifest := `
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: unmapped
+`
+	os, _, err := Parse(manifest, Options{APIVersionMappings: DefaultAPIVersionMappings()})
+	if err == nil {
+		t.Error("expected an error on mapping apiVersions without a RESTMapper")
+	}
+	getObject(t, os, schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}, "", "unmapped")
+}
+
+func TestParseAPIVersionMappingsDefaultSelector(t *testing.T) {
+	manifest := `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: defaulted
+spec:
+  template:
+    metadata:
+      labels:
+        app: foo
+---
+apiVersion: apps/v1beta2
+kind: DaemonSet
+metadata:
+  name: selected
+spec:
+  selector:
+    matchLabels:
+      app: bar
+  template:
+    metadata:
+      labels:
+        app: bar
+        tier: web
+`
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	daemonSetGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}
+	os, _, err := Parse(manifest, Options{
+		RESTMapper:         newTestRESTMapper(deploymentGVK, daemonSetGVK),
+		APIVersionMappings: DefaultAPIVersionMappings(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name             string
+		gvk              schema.GroupVersionKind
+		expectedSelector map[string]interface{}
+	}{
+		{
+			name:             "defaulted",
+			gvk:              deploymentGVK,
+			expectedSelector: map[string]interface{}{"matchLabels": map[string]interface{}{"app": "foo"}},
+		},
+		{
+			name:             "selected",
+			gvk:              daemonSetGVK,
+			expectedSelector: map[string]interface{}{"matchLabels": map[string]interface{}{"app": "bar"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := getObject(t, os, tc.gvk, "", tc.name)
+			selector, _, _ := unstructured.NestedMap(obj.Object, "spec", "selector")
+			if !reflect.DeepEqual(selector, tc.expectedSelector) {
+				t.Errorf("expected selector %v, got %v", tc.expectedSelector, selector)
+			}
+		})
+	}
+}
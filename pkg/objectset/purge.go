@@ -0,0 +1,156 @@
+package objectset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ResourcePolicyAnnotation is the annotation that Helm uses to decide whether to delete an object on uninstalling a release
+	ResourcePolicyAnnotation = "helm.sh/resource-policy"
+
+	// KeepResourcePolicy is the resource policy of objects that Helm does not delete on uninstalling a release
+	KeepResourcePolicy = "keep"
+)
+
+// purge deletes the objects of a GVK applied for a setID, leaving objects annotated with helm.sh/resource-policy: keep
+// in the cluster as Helm does on uninstalling a release
+//
+// Kept objects are no longer tracked by the setID since the labels and annotations that apply.Apply uses to identify
+// them are removed
+func (h *handler) purge(setID string, gvk schema.GroupVersionKind) error {
+	return h.prune(setID, gvk, nil, nil)
+}
+
+// prune deletes the objects of a GVK applied for a setID that are no longer tracked by its ObjectSet, leaving objects
+// annotated with helm.sh/resource-policy: keep in the cluster as Helm does on upgrading or uninstalling a release
+// along with released objects, which are deliberately not tracked by the ObjectSet
+//
+// Kept and released objects are no longer tracked by the setID since the labels and annotations that apply.Apply uses
+// to identify them are removed
+func (h *handler) prune(setID string, gvk schema.GroupVersionKind, desired, released objectset.ObjectByGVK) error {
+	labels, annotations, err := ownerLabelsAndAnnotations(setID)
+	if err != nil {
+		return err
+	}
+	selector, err := apply.GetSelector(labels)
+	if err != nil {
+		return err
+	}
+	c, err := h.clientFactory.ForKind(gvk)
+	if err != nil {
+		return err
+	}
+	// objects are listed from the API server rather than a cache to avoid deleting objects that were just kept
+	list := &unstructured.UnstructuredList{}
+	if err := c.List(context.TODO(), "", list, metav1.ListOptions{LabelSelector: selector.String()}); err != nil {
+		return err
+	}
+	keep, err := keepPatch(labels, annotations)
+	if err != nil {
+		return err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	var errs []error
+	for i := range list.Items {
+		obj := &list.Items[i]
+		switch pruneActionFor(gvk, obj, desired, released) {
+		case keepObject, releaseObject:
+			logrus.Infof("no longer tracking %s %s/%s of objectset %s, leaving it in the cluster", gvk.Kind, obj.GetNamespace(), obj.GetName(), setID)
+			if err := c.Patch(context.TODO(), obj.GetNamespace(), obj.GetName(), types.MergePatchType, keep, &unstructured.Unstructured{}, metav1.PatchOptions{}); err != nil {
+				errs = append(errs, fmt.Errorf("failed to keep %s %s/%s: %w", gvk.Kind, obj.GetNamespace(), obj.GetName(), err))
+			}
+		case deleteObject:
+			logrus.Debugf("pruning %s %s/%s of objectset %s", gvk.Kind, obj.GetNamespace(), obj.GetName(), setID)
+			if err := c.Delete(context.TODO(), obj.GetNamespace(), obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to delete %s %s/%s: %w", gvk.Kind, obj.GetNamespace(), obj.GetName(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pruneAction is how an object applied for a setID is handled on pruning
+type pruneAction int
+
+const (
+	// retainObject leaves an object that is still tracked by the ObjectSet as is
+	retainObject pruneAction = iota
+	// keepObject leaves an object annotated with helm.sh/resource-policy: keep in the cluster but stops tracking it
+	keepObject
+	// releaseObject leaves an object that is deliberately not tracked by the ObjectSet in the cluster but stops tracking it
+	releaseObject
+	// deleteObject deletes an object
+	deleteObject
+)
+
+// pruneActionFor returns how an object of a GVK applied for a setID is handled on pruning it with the desired and released objects
+func pruneActionFor(gvk schema.GroupVersionKind, obj *unstructured.Unstructured, desired, released objectset.ObjectByGVK) pruneAction {
+	if _, ok := desiredObject(desired, gvk, objectset.NewObjectKey(obj)); ok {
+		return retainObject
+	}
+	if _, ok := desiredObject(released, gvk, objectset.NewObjectKey(obj)); ok {
+		return releaseObject
+	}
+	if obj.GetAnnotations()[ResourcePolicyAnnotation] == KeepResourcePolicy {
+		return keepObject
+	}
+	return deleteObject
+}
+
+// pruneObjectSet prunes the objects of each GVK tracked or released by an objectSetState that are no longer tracked by
+// it, one GVK at a time in the order Helm uninstalls them
+func (h *handler) pruneObjectSet(setID string, oss *objectSetState) error {
+	desired := oss.ObjectSet.ObjectsByGVK()
+	gvks := oss.ObjectSet.GVKs()
+	released := objectset.ObjectByGVK{}
+	if oss.released != nil {
+		released = oss.released.ObjectsByGVK()
+		for _, objGVK := range oss.released.GVKs() {
+			if _, ok := desired[objGVK]; !ok {
+				gvks = append(gvks, objGVK)
+			}
+		}
+	}
+	var errs []error
+	for _, objGVK := range sortGVKs(gvks, releaseutil.UninstallOrder) {
+		if err := h.prune(setID, objGVK, desired, released); err != nil {
+			errs = append(errs, fmt.Errorf("failed to prune %s of objectset %s: %w", objGVK.Kind, setID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// keepPatch returns a merge patch that removes the provided labels and annotations as well as the applied annotation
+// added by apply.Apply from an object
+func keepPatch(labels, annotations map[string]string) ([]byte, error) {
+	removedLabels := map[string]interface{}{}
+	for k := range labels {
+		removedLabels[k] = nil
+	}
+	removedAnnotations := map[string]interface{}{
+		apply.LabelApplied: nil,
+	}
+	for k := range annotations {
+		removedAnnotations[k] = nil
+	}
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      removedLabels,
+			"annotations": removedAnnotations,
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package objectset
+
+import (
+	"testing"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+// newConfigMap returns an unstructured ConfigMap with the provided namespace, name, and annotations
+func newConfigMap(namespace, name string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(configMapGVK)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func TestPruneActionFor(t *testing.T) {
+	keep := map[string]string{ResourcePolicyAnnotation: KeepResourcePolicy}
+	desired := objectset.NewObjectSet(
+		newConfigMap("default", "tracked", nil),
+		newConfigMap("", "unqualified", nil),
+		newConfigMap("default", "tracked-kept", keep),
+	).ObjectsByGVK()
+	released := objectset.NewObjectSet(
+		newConfigMap("default", "released", nil),
+	).ObjectsByGVK()
+
+	testCases := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		desired  objectset.ObjectByGVK
+		released objectset.ObjectByGVK
+		action   pruneAction
+	}{
+		{
+			name:    "tracked object",
+			obj:     newConfigMap("default", "tracked", nil),
+			desired: desired,
+			action:  retainObject,
+		},
+		{
+			name:    "tracked object without a namespace in the objectset",
+			obj:     newConfigMap("default", "unqualified", nil),
+			desired: desired,
+			action:  retainObject,
+		},
+		{
+			name:    "tracked object with keep policy",
+			obj:     newConfigMap("default", "tracked-kept", keep),
+			desired: desired,
+			action:  retainObject,
+		},
+		{
+			name:    "untracked object",
+			obj:     newConfigMap("default", "removed", nil),
+			desired: desired,
+			action:  deleteObject,
+		},
+		{
+			name:    "untracked object in another namespace",
+			obj:     newConfigMap("other", "tracked", nil),
+			desired: desired,
+			action:  deleteObject,
+		},
+		{
+			name:    "untracked object with keep policy",
+			obj:     newConfigMap("default", "removed", keep),
+			desired: desired,
+			action:  keepObject,
+		},
+		{
+			name:     "released object",
+			obj:      newConfigMap("default", "released", nil),
+			desired:  desired,
+			released: released,
+			action:   releaseObject,
+		},
+		{
+			name:     "untracked object that is not released",
+			obj:      newConfigMap("default", "removed", nil),
+			desired:  desired,
+			released: released,
+			action:   deleteObject,
+		},
+		{
+			name:    "purged object",
+			obj:     newConfigMap("default", "tracked", nil),
+			desired: nil,
+			action:  deleteObject,
+		},
+		{
+			name:    "purged object with keep policy",
+			obj:     newConfigMap("default", "tracked-kept", keep),
+			desired: nil,
+			action:  keepObject,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if action := pruneActionFor(configMapGVK, tc.obj, tc.desired, tc.released); action != tc.action {
+				t.Errorf("expected action %d, found %d", tc.action, action)
+			}
+		})
+	}
+}
+
+// TestPruneNarrowedObjectSet verifies that objects that were locked before are not deleted when they are released from
+// the ObjectSet, e.g. since the subcharts that rendered them are no longer selected
+func TestPruneNarrowedObjectSet(t *testing.T) {
+	locked := []*unstructured.Unstructured{
+		newConfigMap("default", "umbrella", nil),
+		newConfigMap("default", "logging", nil),
+		newConfigMap("default", "security", nil),
+	}
+	desired := objectset.NewObjectSet(locked[1]).ObjectsByGVK()
+	released := objectset.NewObjectSet(locked[0], locked[2]).ObjectsByGVK()
+	for _, obj := range locked {
+		if action := pruneActionFor(configMapGVK, obj, desired, released); action == deleteObject {
+			t.Errorf("expected %s to not be deleted", obj.GetName())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package objectset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/lasso/pkg/client"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// recreatePollInterval is how often an object that is being re-created is checked for having been deleted
+	recreatePollInterval = time.Second
+
+	// recreateTimeout is how long to wait for an object that is being re-created to be deleted
+	recreateTimeout = 30 * time.Second
+)
+
+// Recreate determines which objects are deleted and re-created when reverting changes to them fails since the
+// object in the cluster can no longer be patched to match the ObjectSet, e.g. since an immutable field was changed
+type Recreate struct {
+	// All re-creates objects of any kind
+	All bool
+	// Kinds are the kinds of objects that are re-created, provided as Kind (e.g. Service) or Kind.group (e.g. Job.batch)
+	Kinds []string
+}
+
+// Matches returns whether objects of a GroupVersionKind should be re-created
+func (r Recreate) Matches(gvk schema.GroupVersionKind) bool {
+	if r.All {
+		return true
+	}
+	groupKind := gvk.GroupKind().String()
+	for _, kind := range r.Kinds {
+		if kind == gvk.Kind || kind == groupKind {
+			return true
+		}
+	}
+	return false
+}
+
+// Or returns a Recreate that re-creates the objects matched by either Recreate
+func (r Recreate) Or(other Recreate) Recreate {
+	return Recreate{
+		All:   r.All || other.All,
+		Kinds: append(append([]string{}, r.Kinds...), other.Kinds...),
+	}
+}
+
+// requiresRecreate returns whether an error returned on applying an object indicates that it can only be
+// reverted by deleting and re-creating it, which is the case if the changes that would be reverted touch an
+// immutable field (e.g. the selector of a Deployment or the spec of a Job)
+//
+// Other validation errors are not resolved by re-creating the object, so they never cause it to be deleted
+func requiresRecreate(err error) bool {
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseType(field.ErrorTypeForbidden) || strings.Contains(cause.Message, "field is immutable") {
+			return true
+		}
+	}
+	return false
+}
+
+// recreator deletes and re-creates objects of an ObjectSet that cannot be patched to match it
+type recreator struct {
+	handler  *handler
+	recreate Recreate
+	desired  objectset.ObjectByGVK
+
+	// labels and annotations are added to re-created objects so that they are still tracked by the ObjectSet
+	labels      map[string]string
+	annotations map[string]string
+
+	// recreated are the objects that were re-created
+	recreated []runtime.Object
+	// recreatedLock is a lock on recreated
+	recreatedLock sync.Mutex
+}
+
+// newRecreator returns a recreator for the objects tracked by an ObjectSet
+func (h *handler) newRecreator(setID string, oss *objectSetState) (*recreator, error) {
+	labels, annotations, err := ownerLabelsAndAnnotations(setID)
+	if err != nil {
+		return nil, err
+	}
+	return &recreator{
+		handler:     h,
+		recreate:    h.recreate.Or(oss.config.Recreate),
+		desired:     oss.ObjectSet.ObjectsByGVK(),
+		labels:      labels,
+		annotations: annotations,
+	}, nil
+}
+
+// shouldRecreate returns whether an object of a GroupVersionKind should be re-created after failing to apply it with err
+func (r *recreator) shouldRecreate(gvk schema.GroupVersionKind, err error) bool {
+	return requiresRecreate(err) && r.recreate.Matches(gvk)
+}
+
+// recreateObject deletes an object with background propagation, waits until it has been removed, and creates the
+// object tracked by the ObjectSet in its place
+func (r *recreator) recreateObject(gvk schema.GroupVersionKind, namespace, name string, create func(c *client.Client) (runtime.Object, error)) (runtime.Object, error) {
+	c, err := r.handler.clientFactory.ForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+	// the object is created in dry-run mode first so that it is not deleted if it cannot be created again, e.g. since
+	// an admission webhook rejects it; the name of the object is still taken, so it already existing is expected
+	if _, err := r.create(gvk, namespace, name, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})(c); err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("refusing to delete %s %s/%s to re-create it since it cannot be created: %w", gvk.Kind, namespace, name, err)
+	}
+	logrus.Warnf("re-creating %s %s/%s since it cannot be updated to match its objectset", gvk.Kind, namespace, name)
+	propagation := metav1.DeletePropagationBackground
+	if err := c.Delete(context.TODO(), namespace, name, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete %s %s/%s to re-create it: %w", gvk.Kind, namespace, name, err)
+	}
+	if err := wait.PollUntilContextTimeout(context.TODO(), recreatePollInterval, recreateTimeout, true, func(ctx context.Context) (bool, error) {
+		err := c.Get(ctx, namespace, name, &unstructured.Unstructured{}, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}); err != nil {
+		return nil, fmt.Errorf("failed waiting for %s %s/%s to be deleted to re-create it: %w", gvk.Kind, namespace, name, err)
+	}
+	result, err := create(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-create %s %s/%s: %w", gvk.Kind, namespace, name, err)
+	}
+	if obj, ok := desiredObject(r.desired, gvk, objectset.ObjectKey{Namespace: namespace, Name: name}); ok {
+		r.recreatedLock.Lock()
+		r.recreated = append(r.recreated, obj)
+		r.recreatedLock.Unlock()
+	}
+	return result, nil
+}
+
+// create creates the object tracked by the ObjectSet with the provided GroupVersionKind, namespace, and name
+func (r *recreator) create(gvk schema.GroupVersionKind, namespace, name string, opts metav1.CreateOptions) func(c *client.Client) (runtime.Object, error) {
+	return func(c *client.Client) (runtime.Object, error) {
+		obj, ok := desiredObject(r.desired, gvk, objectset.ObjectKey{Namespace: namespace, Name: name})
+		if !ok {
+			return nil, fmt.Errorf("%s %s/%s is not tracked by the objectset", gvk.Kind, namespace, name)
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{Object: content}
+		u.SetGroupVersionKind(gvk)
+		u.SetNamespace(namespace)
+		u.SetResourceVersion("")
+		u.SetLabels(merge(u.GetLabels(), r.labels))
+		u.SetAnnotations(merge(u.GetAnnotations(), r.annotations))
+		result := &unstructured.Unstructured{}
+		return result, c.Create(context.TODO(), namespace, u, result, opts)
+	}
+}
+
+// Recreated returns the objects that were re-created
+func (r *recreator) Recreated() []runtime.Object {
+	r.recreatedLock.Lock()
+	defer r.recreatedLock.Unlock()
+	return append([]runtime.Object{}, r.recreated...)
+}
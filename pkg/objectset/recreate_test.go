@@ -0,0 +1,76 @@
+package objectset
+
+import (
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestRequiresRecreate(t *testing.T) {
+	deployments := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "no error",
+			err:      nil,
+			expected: false,
+		},
+		{
+			name: "immutable field",
+			err: apierrors.NewInvalid(deployments, "foo", field.ErrorList{
+				field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable"),
+			}),
+			expected: true,
+		},
+		{
+			name: "forbidden update",
+			err: apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, "foo", field.ErrorList{
+				field.Forbidden(field.NewPath("spec"), "updates to statefulset spec for fields other than 'replicas' are forbidden"),
+			}),
+			expected: true,
+		},
+		{
+			name: "immutable field among other errors",
+			err: apierrors.NewInvalid(deployments, "foo", field.ErrorList{
+				field.Required(field.NewPath("spec", "template"), ""),
+				field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable"),
+			}),
+			expected: true,
+		},
+		{
+			name: "invalid value",
+			err: apierrors.NewInvalid(deployments, "foo", field.ErrorList{
+				field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0"),
+			}),
+			expected: false,
+		},
+		{
+			name:     "bad request",
+			err:      apierrors.NewBadRequest("field is immutable"),
+			expected: false,
+		},
+		{
+			name:     "conflict",
+			err:      apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "foo", fmt.Errorf("the object has been modified")),
+			expected: false,
+		},
+		{
+			name:     "wrapped immutable field",
+			err:      fmt.Errorf("failed to patch: %w", apierrors.NewInvalid(deployments, "foo", field.ErrorList{field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable")})),
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if requiresRecreate(tc.err) != tc.expected {
+				t.Errorf("expected requiresRecreate to return %t for %v", tc.expected, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package objectset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/rancher/lasso/pkg/client"
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// applyServerSide applies the objects tracked by an ObjectSet with server-side apply, forcing conflicts with other field managers
+//
+// Objects are labeled and annotated in the same way as apply.Apply so that they can still be purged once the ObjectSet is deleted
+func (h *handler) applyServerSide(setID string, os *objectset.ObjectSet, corrections Corrections, r *recreator) error {
+	labels, annotations, err := ownerLabelsAndAnnotations(setID)
+	if err != nil {
+		return err
+	}
+
+	force := true
+	opts := metav1.PatchOptions{
+		FieldManager: h.fieldManager,
+		Force:        &force,
+	}
+	var errs []error
+	for _, obj := range os.All() {
+		objGVK, objKey, err := gvkAndKey(obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !corrections.Enforced(objGVK, objKey) {
+			logrus.Debugf("skipping server-side apply of unenforced %s %s", objGVK.Kind, objKey)
+			continue
+		}
+		data, err := serverSideApplyData(obj, labels, annotations)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c, err := h.clientFactory.ForKind(objGVK)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result := &unstructured.Unstructured{}
+		err = c.Patch(context.TODO(), objKey.Namespace, objKey.Name, types.ApplyPatchType, data, result, opts)
+		if r.shouldRecreate(objGVK, err) {
+			_, err = r.recreateObject(objGVK, objKey.Namespace, objKey.Name, func(c *client.Client) (runtime.Object, error) {
+				return result, c.Patch(context.TODO(), objKey.Namespace, objKey.Name, types.ApplyPatchType, data, result, opts)
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to apply %s %s: %w", objGVK.Kind, objKey, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// revertedServerSide returns the Drift of each object of an ObjectSet that would be modified or created on applying it
+// with server-side apply
+//
+// Each object is applied in dry-run mode with the same field manager and compared with the object in the cluster, so
+// only changes to the fields owned by the ObjectSet are drift and differences caused by the API server (e.g. defaults)
+// are never considered drift.
+func (h *handler) revertedServerSide(setID string, os *objectset.ObjectSet) ([]Drift, error) {
+	labels, annotations, err := ownerLabelsAndAnnotations(setID)
+	if err != nil {
+		return nil, err
+	}
+
+	force := true
+	opts := metav1.PatchOptions{
+		FieldManager: h.fieldManager,
+		Force:        &force,
+		DryRun:       []string{metav1.DryRunAll},
+	}
+	var reverted []Drift
+	var errs []error
+	for _, obj := range os.All() {
+		objGVK, objKey, err := gvkAndKey(obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c, err := h.clientFactory.ForKind(objGVK)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		live := &unstructured.Unstructured{}
+		if err := c.Get(context.TODO(), objKey.Namespace, objKey.Name, live, metav1.GetOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				reverted = append(reverted, newCreateDrift(obj))
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to get %s %s: %w", objGVK.Kind, objKey, err))
+			continue
+		}
+		live.SetGroupVersionKind(objGVK)
+		data, err := serverSideApplyData(obj, labels, annotations)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result := &unstructured.Unstructured{}
+		if err := c.Patch(context.TODO(), objKey.Namespace, objKey.Name, types.ApplyPatchType, data, result, opts); err != nil {
+			if requiresRecreate(err) {
+				// the object would be re-created since an immutable field was changed
+				reverted = append(reverted, newUpdateDrift(live, string(data)))
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to dry-run apply %s %s: %w", objGVK.Kind, objKey, err))
+			continue
+		}
+		dropServerManagedFields(live.Object)
+		dropServerManagedFields(result.Object)
+		if reflect.DeepEqual(live.Object, result.Object) {
+			continue
+		}
+		changes, err := json.Marshal(result.Object)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		reverted = append(reverted, newUpdateDrift(live, string(changes)))
+	}
+	return reverted, errors.Join(errs...)
+}
+
+// dropServerManagedFields removes the metadata fields of an object that the API server updates on every write from its
+// content, so that an object can be compared with the result of applying it
+func dropServerManagedFields(content map[string]interface{}) {
+	unstructured.RemoveNestedField(content, "metadata", "managedFields")
+	unstructured.RemoveNestedField(content, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(content, "metadata", "generation")
+}
+
+// serverSideApplyData returns the apply configuration of an object of an ObjectSet, with the labels and annotations
+// that identify the ObjectSet
+func serverSideApplyData(obj runtime.Object, labels, annotations map[string]string) ([]byte, error) {
+	// the content of unstructured objects is not copied on conversion
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetLabels(merge(u.GetLabels(), labels))
+	u.SetAnnotations(merge(u.GetAnnotations(), annotations))
+	return json.Marshal(u)
+}
+
+// ownerLabelsAndAnnotations returns the labels and annotations that apply.Apply adds to objects applied for a setID
+func ownerLabelsAndAnnotations(setID string) (map[string]string, map[string]string, error) {
+	key := relatedresource.FromString(setID)
+	owner := &metav1.PartialObjectMetadata{}
+	owner.Namespace, owner.Name = key.Namespace, key.Name
+	owner.SetGroupVersionKind(internalGroupVersion.WithKind("objectSetState"))
+	return apply.GetLabelsAndAnnotations(objectSetApplierID, owner)
+}
+
+// merge returns a map with the entries of both maps, preferring the entries of override
+func merge(base, override map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,47 @@
+package objectset
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestServerSideApplyData(t *testing.T) {
+	obj := newConfigMap("default", "foo", map[string]string{"foo": "bar"})
+	original := obj.DeepCopy()
+
+	data, err := serverSideApplyData(obj, map[string]string{"owner": "foochart"}, map[string]string{"owned": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied map[string]interface{}
+	if err := json.Unmarshal(data, &applied); err != nil {
+		t.Fatal(err)
+	}
+	metadata := applied["metadata"].(map[string]interface{})
+	if !reflect.DeepEqual(metadata["labels"], map[string]interface{}{"owner": "foochart"}) {
+		t.Errorf("expected owner labels to be applied, got %v", metadata["labels"])
+	}
+	if !reflect.DeepEqual(metadata["annotations"], map[string]interface{}{"foo": "bar", "owned": "true"}) {
+		t.Errorf("expected owner annotations to be merged into the annotations of the object, got %v", metadata["annotations"])
+	}
+	if !reflect.DeepEqual(obj, original) {
+		t.Errorf("expected the object of the objectset to not be modified, got %v", obj)
+	}
+}
+
+func TestDropServerManagedFields(t *testing.T) {
+	live := newConfigMap("default", "foo", nil)
+	live.SetResourceVersion("1")
+	live.SetGeneration(1)
+	live.Object["data"] = map[string]interface{}{"foo": "bar"}
+	applied := live.DeepCopy()
+	applied.SetResourceVersion("2")
+	applied.SetGeneration(2)
+
+	dropServerManagedFields(live.Object)
+	dropServerManagedFields(applied.Object)
+	if !reflect.DeepEqual(live.Object, applied.Object) {
+		t.Errorf("expected objects that only differ in fields updated by the API server to be equal, got %v and %v", live.Object, applied.Object)
+	}
+}
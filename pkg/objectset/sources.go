@@ -0,0 +1,42 @@
+package objectset
+
+import (
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Sources identifies the template that rendered each object tracked by an ObjectSet (e.g. chart/templates/configmap.yaml)
+type Sources map[schema.GroupVersionKind]map[objectset.ObjectKey]string
+
+// Add records the template that rendered an object
+func (s Sources) Add(obj runtime.Object, source string) error {
+	gvk, key, err := gvkAndKey(obj)
+	if err != nil {
+		return err
+	}
+	if _, ok := s[gvk]; !ok {
+		s[gvk] = map[objectset.ObjectKey]string{}
+	}
+	s[gvk][key] = source
+	return nil
+}
+
+// Get returns the template that rendered the object with the provided GVK and key, if known
+func (s Sources) Get(gvk schema.GroupVersionKind, key objectset.ObjectKey) string {
+	sourceByKey := s[gvk]
+	if source, ok := sourceByKey[key]; ok {
+		return source
+	}
+	// objects that do not specify a namespace in the manifest are tracked without one
+	return sourceByKey[objectset.ObjectKey{Name: key.Name}]
+}
+
+// For returns the template that rendered an object, if known
+func (s Sources) For(obj runtime.Object) string {
+	gvk, key, err := gvkAndKey(obj)
+	if err != nil {
+		return ""
+	}
+	return s.Get(gvk, key)
+}
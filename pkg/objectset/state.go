@@ -29,6 +29,39 @@ func addInternalTypes(scheme *runtime.Scheme) error {
 	return nil
 }
 
+// Applied is the runtime.Object that handlers registered on the SharedHandler returned by NewLockableRegister
+// receive after an ObjectSet has been applied
+type Applied interface {
+	runtime.Object
+
+	// GetObjectSet returns the ObjectSet that was applied
+	GetObjectSet() *objectset.ObjectSet
+
+	// Reverted returns the objects that had drifted from the ObjectSet and were reverted on the last apply
+	Reverted() []runtime.Object
+
+	// Drifts returns how each object that had drifted from the ObjectSet was reverted on the last apply
+	Drifts() []Drift
+
+	// Corrections returns the corrections made to objects tracked by the ObjectSet since it was locked
+	Corrections() Corrections
+
+	// Conflicts returns the conflicts with other ObjectSets that track the same objects that were found on locking the ObjectSet
+	Conflicts() []Conflict
+
+	// Contested returns the objects that were reverted too often and became contested on the last apply
+	Contested() []runtime.Object
+
+	// Unenforceable returns the objects that did not match the ObjectSet after being reverted and became unenforceable on the last apply
+	Unenforceable() []runtime.Object
+	// Recreated returns the objects that were deleted and re-created since they could not be updated to match the ObjectSet on the last apply
+	Recreated() []runtime.Object
+	// Sources returns the templates that rendered the objects of the ObjectSet, if known
+	Sources() Sources
+	// Strays returns the stray objects that were found, and pruned if configured to, on the last apply
+	Strays() []Stray
+}
+
 // newObjectSetState returns a new objectSetState for internal consumption
 func newObjectSetState(namespace, name string, obj objectSetState) *objectSetState {
 	obj.APIVersion, obj.Kind = internalGroupVersion.WithKind("objectSetState").ToAPIVersionAndKind()
@@ -56,6 +89,91 @@ type objectSetState struct {
 
 	// Locked represents whether the ObjectSet should be locked in the cluster or not
 	Locked bool `json:"locked"`
+
+	// reverted are the Drifts of objects that had drifted from the ObjectSet and were reverted on the last apply
+	reverted []Drift
+	// corrections are the corrections made to objects tracked by the ObjectSet since it was locked
+	corrections Corrections
+	// conflicts are the conflicts with other ObjectSets that were found on locking the ObjectSet
+	conflicts []Conflict
+	// contested are the objects that were reverted too often and became contested on the last apply
+	contested []runtime.Object
+	// unenforceable are the objects that did not match the ObjectSet after being reverted and became unenforceable on the last apply
+	unenforceable []runtime.Object
+	// recreated are the objects that were deleted and re-created since they could not be updated to match the ObjectSet on the last apply
+	recreated []runtime.Object
+	// strays are the stray objects that were found, and pruned if configured to, on the last apply
+	strays []Stray
+
+	// config overrides how the ObjectSet is locked
+	config Config
+	// sources are the templates that rendered the objects of the ObjectSet
+	sources Sources
+	// released are the objects that are deliberately not tracked by the ObjectSet, which are left in the cluster rather
+	// than pruned if they were applied for it
+	released *objectset.ObjectSet
+
+	// digest is the digest of the contents of the ObjectSet
+	digest string
+	// modifiedAt is the last time the ObjectSet was set with different contents
+	modifiedAt time.Time
+}
+
+// GetObjectSet returns the ObjectSet that was applied
+func (in *objectSetState) GetObjectSet() *objectset.ObjectSet {
+	return in.ObjectSet
+}
+
+// Reverted returns the objects that had drifted from the ObjectSet and were reverted on the last apply
+func (in *objectSetState) Reverted() []runtime.Object {
+	if in.reverted == nil {
+		return nil
+	}
+	reverted := make([]runtime.Object, len(in.reverted))
+	for i, drift := range in.reverted {
+		reverted[i] = drift.Object
+	}
+	return reverted
+}
+
+// Drifts returns how each object that had drifted from the ObjectSet was reverted on the last apply
+func (in *objectSetState) Drifts() []Drift {
+	return in.reverted
+}
+
+// Corrections returns the corrections made to objects tracked by the ObjectSet since it was locked
+func (in *objectSetState) Corrections() Corrections {
+	return in.corrections
+}
+
+// Conflicts returns the conflicts with other ObjectSets that track the same objects that were found on locking the ObjectSet
+func (in *objectSetState) Conflicts() []Conflict {
+	return in.conflicts
+}
+
+// Contested returns the objects that were reverted too often and became contested on the last apply
+func (in *objectSetState) Contested() []runtime.Object {
+	return in.contested
+}
+
+// Unenforceable returns the objects that did not match the ObjectSet after being reverted and became unenforceable on the last apply
+func (in *objectSetState) Unenforceable() []runtime.Object {
+	return in.unenforceable
+}
+
+// Recreated returns the objects that were deleted and re-created since they could not be updated to match the ObjectSet on the last apply
+func (in *objectSetState) Recreated() []runtime.Object {
+	return in.recreated
+}
+
+// Sources returns the templates that rendered the objects of the ObjectSet, if known
+func (in *objectSetState) Sources() Sources {
+	return in.sources
+}
+
+// Strays returns the stray objects that were found, and pruned if configured to, on the last apply
+func (in *objectSetState) Strays() []Stray {
+	return in.strays
 }
 
 // DeepCopyInto is a deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -0,0 +1,173 @@
+package objectset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ManagedByLabel is the label that Helm sets on the objects of a release
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// ManagedByHelm is the value of the ManagedByLabel on the objects of a Helm release
+	ManagedByHelm = "Helm"
+
+	// ReleaseNameAnnotation is the annotation that Helm sets to the name of the release that owns an object
+	ReleaseNameAnnotation = "meta.helm.sh/release-name"
+
+	// ReleaseNamespaceAnnotation is the annotation that Helm sets to the namespace of the release that owns an object
+	ReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+
+	// HookAnnotation is the annotation that identifies the resources of Helm hooks
+	HookAnnotation = "helm.sh/hook"
+)
+
+// StrayObjects configures strict mode, which finds stray objects on applying an ObjectSet
+//
+// Stray objects carry the Helm ownership labels and annotations of the release identified by the key of the ObjectSet
+// but are not tracked by it, e.g. since they were created with kubectl. Objects rendered by the release (see Sources),
+// resources of Helm hooks, objects annotated with helm.sh/resource-policy: keep, objects released by the ObjectSet, and
+// objects applied by the ObjectSet itself are never stray.
+type StrayObjects struct {
+	// Enabled finds the stray objects of the kinds tracked by the ObjectSet
+	Enabled bool
+	// Prune deletes stray objects rather than only reporting them
+	Prune bool
+	// DryRun reports the stray objects that would be deleted by Prune without deleting them
+	DryRun bool
+}
+
+// Stray is an object found in the cluster that claims to belong to the Helm release of an ObjectSet but is not tracked by it
+type Stray struct {
+	// Object is the stray object
+	Object runtime.Object
+	// Pruned is whether the stray object was deleted
+	Pruned bool
+}
+
+// strays returns the stray objects of the kinds tracked by an objectSetState, deleting them if configured to
+func (h *handler) strays(setID string, oss *objectSetState) ([]Stray, error) {
+	if !oss.config.StrayObjects.Enabled || oss.config.Incomplete {
+		return nil, nil
+	}
+	labels, _, err := ownerLabelsAndAnnotations(setID)
+	if err != nil {
+		return nil, err
+	}
+	var strays []Stray
+	var errs []error
+	for _, objGVK := range oss.ObjectSet.GVKs() {
+		c, err := h.clientFactory.ForKind(objGVK)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		list := &unstructured.UnstructuredList{}
+		if err := c.List(context.TODO(), "", list, metav1.ListOptions{LabelSelector: ManagedByLabel + "=" + ManagedByHelm}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %w", objGVK.Kind, err))
+			continue
+		}
+		gvkStrays, err := findStrays(setID, labels[apply.LabelHash], oss, objGVK, list.Items, c)
+		strays = append(strays, gvkStrays...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return strays, errors.Join(errs...)
+}
+
+// objectDeleter deletes objects of a single kind
+type objectDeleter interface {
+	Delete(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error
+}
+
+// findStrays returns the stray objects among the objects of a GVK listed in the cluster, deleting them with the deleter
+// if the objectSetState is configured to prune them
+func findStrays(setID, setHash string, oss *objectSetState, objGVK schema.GroupVersionKind, objs []unstructured.Unstructured, deleter objectDeleter) ([]Stray, error) {
+	config := oss.config.StrayObjects
+	key := relatedresource.FromString(setID)
+	desired := oss.ObjectSet.ObjectsByGVK()
+	var released objectset.ObjectByGVK
+	if oss.released != nil {
+		released = oss.released.ObjectsByGVK()
+	}
+	propagation := metav1.DeletePropagationBackground
+	var strays []Stray
+	var errs []error
+	for i := range objs {
+		obj := &objs[i]
+		obj.SetGroupVersionKind(objGVK)
+		if !ownedByRelease(obj, key, setHash) || len(oss.sources.For(obj)) > 0 {
+			continue
+		}
+		if _, ok := desiredObject(desired, objGVK, objectset.NewObjectKey(obj)); ok {
+			continue
+		}
+		if _, ok := desiredObject(released, objGVK, objectset.NewObjectKey(obj)); ok {
+			continue
+		}
+		stray := Stray{Object: obj}
+		if config.Prune && !config.DryRun {
+			logrus.Infof("pruning stray %s %s/%s of objectset %s", objGVK.Kind, obj.GetNamespace(), obj.GetName(), setID)
+			err := deleter.Delete(context.TODO(), obj.GetNamespace(), obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("failed to delete stray %s %s/%s: %w", objGVK.Kind, obj.GetNamespace(), obj.GetName(), err))
+			} else {
+				stray.Pruned = true
+			}
+		} else {
+			logrus.Warnf("found stray %s %s/%s of objectset %s", objGVK.Kind, obj.GetNamespace(), obj.GetName(), setID)
+		}
+		strays = append(strays, stray)
+	}
+	return strays, errors.Join(errs...)
+}
+
+// ownedByRelease returns whether an object carries the Helm ownership annotations of the release with the provided key
+// and is neither the resource of a Helm hook, left behind by Helm on purpose, nor applied by the ObjectSet with the
+// provided hash
+//
+// Objects that carry the hash of another ObjectSet (e.g. copied from another release along with its labels) are not
+// tracked by this ObjectSet, so they are still owned by the release.
+func ownedByRelease(obj metav1.Object, key relatedresource.Key, setHash string) bool {
+	annotations := obj.GetAnnotations()
+	if annotations[ReleaseNameAnnotation] != key.Name || annotations[ReleaseNamespaceAnnotation] != key.Namespace {
+		return false
+	}
+	if _, ok := annotations[HookAnnotation]; ok {
+		return false
+	}
+	if annotations[ResourcePolicyAnnotation] == KeepResourcePolicy {
+		return false
+	}
+	return obj.GetLabels()[apply.LabelHash] != setHash
+}
+
+// releaseKeyOf returns the key of the Helm release that an object claims to belong to, if any
+func releaseKeyOf(obj runtime.Object) (relatedresource.Key, bool) {
+	if obj == nil {
+		return relatedresource.Key{}, false
+	}
+	metadata, err := meta.Accessor(obj)
+	if err != nil || metadata.GetLabels()[ManagedByLabel] != ManagedByHelm {
+		return relatedresource.Key{}, false
+	}
+	annotations := metadata.GetAnnotations()
+	name, namespace := annotations[ReleaseNameAnnotation], annotations[ReleaseNamespaceAnnotation]
+	if len(name) == 0 || len(namespace) == 0 {
+		return relatedresource.Key{}, false
+	}
+	return relatedresource.Key{Namespace: namespace, Name: name}, true
+}
@@ -0,0 +1,153 @@
+package objectset
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/rancher/wrangler/v3/pkg/apply"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// recordingDeleter records the namespace/name of each object it is asked to delete
+type recordingDeleter struct {
+	deleted []string
+}
+
+func (d *recordingDeleter) Delete(_ context.Context, namespace, name string, _ metav1.DeleteOptions) error {
+	d.deleted = append(d.deleted, namespace+"/"+name)
+	return nil
+}
+
+// newReleaseObject returns a ConfigMap that carries the Helm ownership annotations of the foo/foochart release along with
+// the provided annotations and labels
+func newReleaseObject(name string, annotations, labels map[string]string) unstructured.Unstructured {
+	obj := newConfigMap("foo", name, merge(map[string]string{
+		ReleaseNameAnnotation:      "foochart",
+		ReleaseNamespaceAnnotation: "foo",
+	}, annotations))
+	obj.SetLabels(merge(map[string]string{ManagedByLabel: ManagedByHelm}, labels))
+	return *obj
+}
+
+func TestOwnedByRelease(t *testing.T) {
+	key := relatedresource.Key{Namespace: "foo", Name: "foochart"}
+	testCases := []struct {
+		name     string
+		obj      unstructured.Unstructured
+		expected bool
+	}{
+		{
+			name:     "release object",
+			obj:      newReleaseObject("a", nil, nil),
+			expected: true,
+		},
+		{
+			name:     "object of another release",
+			obj:      *newConfigMap("foo", "a", map[string]string{ReleaseNameAnnotation: "barchart", ReleaseNamespaceAnnotation: "foo"}),
+			expected: false,
+		},
+		{
+			name:     "hook resource",
+			obj:      newReleaseObject("a", map[string]string{HookAnnotation: "pre-install"}, nil),
+			expected: false,
+		},
+		{
+			name:     "kept object",
+			obj:      newReleaseObject("a", map[string]string{ResourcePolicyAnnotation: KeepResourcePolicy}, nil),
+			expected: false,
+		},
+		{
+			name:     "applied by this objectset",
+			obj:      newReleaseObject("a", nil, map[string]string{apply.LabelHash: "thishash"}),
+			expected: false,
+		},
+		{
+			name:     "applied by another objectset",
+			obj:      newReleaseObject("a", nil, map[string]string{apply.LabelHash: "otherhash"}),
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if ownedByRelease(&tc.obj, key, "thishash") != tc.expected {
+				t.Errorf("expected ownedByRelease to return %t", tc.expected)
+			}
+		})
+	}
+}
+
+func TestFindStrays(t *testing.T) {
+	tracked := newReleaseObject("tracked", nil, nil)
+	released := newReleaseObject("released", nil, nil)
+	rendered := newReleaseObject("rendered", nil, nil)
+	sources := Sources{}
+	if err := sources.Add(&rendered, "foochart/templates/rendered.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	listed := func() []unstructured.Unstructured {
+		return []unstructured.Unstructured{
+			newReleaseObject("tracked", nil, map[string]string{apply.LabelHash: "thishash"}),
+			newReleaseObject("released", nil, nil),
+			newReleaseObject("rendered", nil, nil),
+			newReleaseObject("created-with-kubectl", nil, nil),
+			newReleaseObject("copied-from-another-release", nil, map[string]string{apply.LabelHash: "otherhash"}),
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		config   StrayObjects
+		expected []string
+		deleted  []string
+	}{
+		{
+			name:     "report",
+			config:   StrayObjects{Enabled: true},
+			expected: []string{"created-with-kubectl", "copied-from-another-release"},
+		},
+		{
+			name:     "prune",
+			config:   StrayObjects{Enabled: true, Prune: true},
+			expected: []string{"created-with-kubectl", "copied-from-another-release"},
+			deleted:  []string{"foo/created-with-kubectl", "foo/copied-from-another-release"},
+		},
+		{
+			name:     "prune dry run",
+			config:   StrayObjects{Enabled: true, Prune: true, DryRun: true},
+			expected: []string{"created-with-kubectl", "copied-from-another-release"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			oss := &objectSetState{
+				ObjectSet: objectset.NewObjectSet(&tracked),
+				config:    Config{StrayObjects: tc.config},
+				sources:   sources,
+				released:  objectset.NewObjectSet(&released),
+			}
+			deleter := &recordingDeleter{}
+			strays, err := findStrays("foo/foochart", "thishash", oss, configMapGVK, listed(), deleter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, stray := range strays {
+				u := stray.Object.(*unstructured.Unstructured)
+				names = append(names, u.GetName())
+				if stray.Pruned != (len(tc.deleted) > 0) {
+					t.Errorf("expected stray %s to have pruned set to %t", u.GetName(), len(tc.deleted) > 0)
+				}
+			}
+			if !slices.Equal(names, tc.expected) {
+				t.Errorf("expected strays %v, got %v", tc.expected, names)
+			}
+			if !slices.Equal(deleter.deleted, tc.deleted) {
+				t.Errorf("expected %v to be deleted, got %v", tc.deleted, deleter.deleted)
+			}
+		})
+	}
+}
@@ -1,7 +1,17 @@
 package objectset
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/rancher/wrangler/v3/pkg/gvk"
+	"github.com/rancher/wrangler/v3/pkg/objectset"
 	"github.com/rancher/wrangler/v3/pkg/relatedresource"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // keyFunc is a utility function that returns a relatedresource.Key from a namespace and a name
@@ -11,3 +21,61 @@ func keyFunc(namespace, name string) relatedresource.Key {
 		Name:      name,
 	}
 }
+
+// digest returns a digest of the contents of the provided ObjectSets, skipping any that are nil
+func digest(sets ...*objectset.ObjectSet) (string, error) {
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+	for i, os := range sets {
+		if os == nil {
+			continue
+		}
+		// separate the objects of each ObjectSet
+		if err := encoder.Encode(i); err != nil {
+			return "", err
+		}
+		for _, obj := range os.All() {
+			if err := encoder.Encode(obj); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// without returns an ObjectSet with all the objects of the provided ObjectSet except for the excluded objects
+func without(os *objectset.ObjectSet, excluded []runtime.Object) *objectset.ObjectSet {
+	if len(excluded) == 0 {
+		return os
+	}
+	excludedByGVK := objectset.ObjectByGVK{}
+	for _, obj := range excluded {
+		if _, err := excludedByGVK.Add(obj); err != nil {
+			logrus.Errorf("unable to exclude object from objectset: %s", err)
+		}
+	}
+	filtered := objectset.NewObjectSet()
+	for _, obj := range os.All() {
+		objGVK, objKey, err := gvkAndKey(obj)
+		if err == nil {
+			if _, ok := excludedByGVK[objGVK][objKey]; ok {
+				continue
+			}
+		}
+		filtered.Add(obj)
+	}
+	return filtered
+}
+
+// gvkAndKey returns the GroupVersionKind and objectset.ObjectKey that identify an object
+func gvkAndKey(obj runtime.Object) (schema.GroupVersionKind, objectset.ObjectKey, error) {
+	metadata, err := meta.Accessor(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, objectset.ObjectKey{}, err
+	}
+	objGVK, err := gvk.Get(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, objectset.ObjectKey{}, err
+	}
+	return objGVK, objectset.NewObjectKey(metadata), nil
+}
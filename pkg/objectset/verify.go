@@ -0,0 +1,153 @@
+package objectset
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/rancher/wrangler/v3/pkg/objectset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// maxUnconvergedApplies is the number of consecutive applies after which an object that still does not match
+	// the ObjectSet is considered Unenforceable
+	maxUnconvergedApplies = 3
+)
+
+// verify re-reads an object that was just applied and returns the field paths of the desired object that the object
+// in the cluster does not match once both are normalized, e.g. due to a mutating admission webhook
+func (h *handler) verify(objGVK schema.GroupVersionKind, namespace, name string, desired runtime.Object) ([]string, error) {
+	c, err := h.clientFactory.ForKind(objGVK)
+	if err != nil {
+		return nil, err
+	}
+	live := &unstructured.Unstructured{}
+	if err := c.Get(context.TODO(), namespace, name, live, metav1.GetOptions{}); err != nil {
+		return nil, err
+	}
+	return h.normalizers.divergentPaths(desired, live)
+}
+
+// divergentPaths returns the field paths of the desired object whose values do not match the live object
+//
+// Fields that are only set on the live object (e.g. defaults set by the API server) and fields managed by the
+// API server (e.g. status and most metadata) are ignored
+func divergentPaths(desired, live map[string]interface{}) []string {
+	var paths []string
+	for _, field := range sortedKeys(desired) {
+		switch field {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			desiredMetadata, _ := desired[field].(map[string]interface{})
+			liveMetadata, _ := live[field].(map[string]interface{})
+			for _, metadataField := range []string{"labels", "annotations"} {
+				paths = append(paths, divergentValuePaths("metadata."+metadataField, desiredMetadata[metadataField], liveMetadata[metadataField])...)
+			}
+		default:
+			paths = append(paths, divergentValuePaths(field, desired[field], live[field])...)
+		}
+	}
+	return paths
+}
+
+// divergentValuePaths returns the field paths under path whose values in desired do not match live
+func divergentValuePaths(path string, desired, live interface{}) []string {
+	if isEmpty(desired) && isEmpty(live) {
+		return nil
+	}
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+		var paths []string
+		for _, k := range sortedKeys(d) {
+			paths = append(paths, divergentValuePaths(path+"."+k, d[k], l[k])...)
+		}
+		return paths
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return []string{path}
+		}
+		var paths []string
+		for i := range d {
+			paths = append(paths, divergentValuePaths(fmt.Sprintf("%s[%d]", path, i), d[i], l[i])...)
+		}
+		return paths
+	default:
+		if !equalValues(desired, live) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+// isEmpty returns whether a value is unset or an empty map or list
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// equalValues returns whether two scalar values are equal, regardless of the numeric type they were decoded into
+func equalValues(a, b interface{}) bool {
+	aNum, aIsNum := toFloat(a)
+	bNum, bIsNum := toFloat(b)
+	if aIsNum && bIsNum {
+		return aNum == bNum
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat returns a numeric value as a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// desiredObject returns the object in the ObjectSet that corresponds to an object in the cluster
+func desiredObject(desired objectset.ObjectByGVK, objGVK schema.GroupVersionKind, objKey objectset.ObjectKey) (runtime.Object, bool) {
+	if obj, ok := desired[objGVK][objKey]; ok {
+		return obj, true
+	}
+	// namespaced objects may not specify a namespace in the ObjectSet
+	obj, ok := desired[objGVK][objectset.ObjectKey{Name: objKey.Name}]
+	return obj, ok
+}
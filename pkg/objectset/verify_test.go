@@ -0,0 +1,119 @@
+package objectset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDivergentPaths(t *testing.T) {
+	testCases := []struct {
+		name     string
+		desired  map[string]interface{}
+		live     map[string]interface{}
+		expected []string
+	}{
+		{
+			name: "matching objects",
+			desired: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"data":       map[string]interface{}{"foo": "bar"},
+			},
+			live: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"data":       map[string]interface{}{"foo": "bar"},
+			},
+		},
+		{
+			name: "fields only set on the live object",
+			desired: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": int64(1)},
+			},
+			live: map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": int64(1), "revisionHistoryLimit": int64(10)},
+				"status": map[string]interface{}{"replicas": int64(1)},
+			},
+		},
+		{
+			name: "server managed fields",
+			desired: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "foo", "labels": map[string]interface{}{"app": "foo"}},
+				"status":   map[string]interface{}{"phase": "Pending"},
+			},
+			live: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "foo", "uid": "1234", "labels": map[string]interface{}{"app": "foo"}},
+				"status":   map[string]interface{}{"phase": "Running"},
+			},
+		},
+		{
+			name: "numeric types",
+			desired: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": 1, "ratio": float64(2)},
+			},
+			live: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": int64(1), "ratio": int64(2)},
+			},
+		},
+		{
+			name: "empty values",
+			desired: map[string]interface{}{
+				"data": map[string]interface{}{},
+				"spec": map[string]interface{}{"items": []interface{}{}},
+			},
+			live: map[string]interface{}{
+				"spec": map[string]interface{}{},
+			},
+		},
+		{
+			name: "changed values",
+			desired: map[string]interface{}{
+				"metadata": map[string]interface{}{"annotations": map[string]interface{}{"foo": "bar"}},
+				"data":     map[string]interface{}{"foo": "bar", "baz": "qux"},
+			},
+			live: map[string]interface{}{
+				"metadata": map[string]interface{}{"annotations": map[string]interface{}{"foo": "changed"}},
+				"data":     map[string]interface{}{"foo": "changed"},
+			},
+			expected: []string{"data.baz", "data.foo", "metadata.annotations.foo"},
+		},
+		{
+			name: "changed lists",
+			desired: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "app:v1"},
+					},
+					"args": []interface{}{"a", "b"},
+				},
+			},
+			live: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "app:v2"},
+					},
+					"args": []interface{}{"a"},
+				},
+			},
+			expected: []string{"spec.args", "spec.containers[0].image"},
+		},
+		{
+			name: "changed types",
+			desired: map[string]interface{}{
+				"spec": map[string]interface{}{"selector": map[string]interface{}{"app": "foo"}},
+			},
+			live: map[string]interface{}{
+				"spec": map[string]interface{}{"selector": "foo"},
+			},
+			expected: []string{"spec.selector"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths := divergentPaths(tc.desired, tc.live)
+			if !reflect.DeepEqual(paths, tc.expected) {
+				t.Errorf("expected divergent paths %v, got %v", tc.expected, paths)
+			}
+		})
+	}
+}
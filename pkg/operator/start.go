@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/rancher/helm-locker/pkg/controllers"
+	"github.com/rancher/helm-locker/pkg/controllers/release"
 	"github.com/rancher/helm-locker/pkg/crd"
+	"github.com/rancher/helm-locker/pkg/metrics"
+	"github.com/rancher/helm-locker/pkg/notifier"
+	"github.com/rancher/helm-locker/pkg/objectset"
+	"github.com/rancher/helm-locker/pkg/objectset/parser"
 	"github.com/rancher/wrangler/v3/pkg/ratelimit"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -19,6 +25,46 @@ type ControllerOptions struct {
 	ControllerName string
 	NodeName       string
 	PprofEnabled   bool
+
+	// NotifierConfigMap is the name of a ConfigMap in the system namespace that configures drift notification sinks
+	NotifierConfigMap string
+	// NotifierWebhookURLs are URLs that receive drift notifications as generic JSON payloads
+	NotifierWebhookURLs []string
+	// NotifierCloudEventsURLs are URLs that receive drift notifications as CloudEvents
+	NotifierCloudEventsURLs []string
+	// NotifierSlackURLs are Slack-compatible incoming webhook URLs that receive drift notifications
+	NotifierSlackURLs []string
+
+	// PolicyReportsEnabled enables publishing the results of locking releases as wg-policy PolicyReports
+	PolicyReportsEnabled bool
+
+	// ConflictPolicy determines which release locks an object tracked by more than one release
+	ConflictPolicy string
+
+	// ApplyMode determines whether objects are applied with client-side or server-side apply
+	ApplyMode string
+	// FieldManager is the field manager used to apply objects with server-side apply
+	FieldManager string
+
+	// FlapThreshold is the number of times an object can be reverted within FlapWindow before it is no longer reverted
+	FlapThreshold int
+	// FlapWindow is the window within which reverts of an object are counted towards FlapThreshold
+	FlapWindow time.Duration
+
+	// PurgeGracePeriod is the time that a Helm release secret must not be found for before the objects of the release are purged
+	PurgeGracePeriod time.Duration
+	// MaxPurgeObjects is the maximum number of objects of a Helm release that are purged without confirmation; 0 disables the limit
+	MaxPurgeObjects int
+
+	// RecreateKinds are the kinds of objects that are deleted and re-created if reverting changes to them fails, or "*" for any kind
+	RecreateKinds []string
+
+	// APIVersionMappings are mappings of unserved apiVersions in release manifests to served ones, provided as
+	// Kind.version.group=group/version, on top of the built-in mappings for apiVersions removed from Kubernetes
+	APIVersionMappings []string
+
+	// MetricsAddress is the address that metrics are served on, if provided
+	MetricsAddress string
 }
 
 func (c ControllerOptions) Validate() error {
@@ -26,6 +72,36 @@ func (c ControllerOptions) Validate() error {
 		return fmt.Errorf("helm-locker can only be started in a single namespace")
 	}
 
+	if len(c.ConflictPolicy) > 0 {
+		if err := objectset.ConflictPolicy(c.ConflictPolicy).Validate(); err != nil {
+			return err
+		}
+	}
+
+	if len(c.ApplyMode) > 0 {
+		if err := objectset.ApplyMode(c.ApplyMode).Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.lockOptions().FlapDetection.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.purgeOptions().Validate(); err != nil {
+		return err
+	}
+
+	if _, err := parser.ParseAPIVersionMappings(c.APIVersionMappings); err != nil {
+		return err
+	}
+
+	for _, sink := range c.notifierOptions().Sinks {
+		if err := sink.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -40,6 +116,10 @@ func Run(ctx context.Context, options ControllerOptions) error {
 		}()
 	}
 
+	if len(options.MetricsAddress) > 0 {
+		go metrics.Serve(ctx, options.MetricsAddress)
+	}
+
 	clientConfig, err := options.ClientConfig.ClientConfig()
 	if err != nil {
 		return err
@@ -47,6 +127,11 @@ func Run(ctx context.Context, options ControllerOptions) error {
 
 	clientConfig.RateLimiter = ratelimit.None
 
+	apiVersionMappings, err := parser.ParseAPIVersionMappings(options.APIVersionMappings)
+	if err != nil {
+		return err
+	}
+
 	if err := crd.Create(ctx, clientConfig); err != nil {
 		return err
 	}
@@ -57,6 +142,13 @@ func Run(ctx context.Context, options ControllerOptions) error {
 		options.ControllerName,
 		options.NodeName,
 		options.ClientConfig,
+		controllers.Options{
+			Notifier:           options.notifierOptions(),
+			PolicyReports:      options.PolicyReportsEnabled,
+			Lock:               options.lockOptions(),
+			Purge:              options.purgeOptions(),
+			APIVersionMappings: apiVersionMappings,
+		},
 	); err != nil {
 		return err
 	}
@@ -64,3 +156,55 @@ func Run(ctx context.Context, options ControllerOptions) error {
 	<-ctx.Done()
 	return nil
 }
+
+// lockOptions returns the objectset.LockOptions configured by these ControllerOptions
+func (c ControllerOptions) lockOptions() objectset.LockOptions {
+	return objectset.LockOptions{
+		ConflictPolicy: objectset.ConflictPolicy(c.ConflictPolicy),
+		FlapDetection: objectset.FlapDetection{
+			Threshold: c.FlapThreshold,
+			Window:    c.FlapWindow,
+		},
+		ApplyMode:    objectset.ApplyMode(c.ApplyMode),
+		FieldManager: c.FieldManager,
+		Recreate:     c.recreate(),
+	}
+}
+
+// purgeOptions returns the release.PurgeOptions configured by these ControllerOptions
+func (c ControllerOptions) purgeOptions() release.PurgeOptions {
+	return release.PurgeOptions{
+		GracePeriod: c.PurgeGracePeriod,
+		MaxObjects:  c.MaxPurgeObjects,
+	}
+}
+
+// recreate returns the objectset.Recreate configured by these ControllerOptions
+func (c ControllerOptions) recreate() objectset.Recreate {
+	var recreate objectset.Recreate
+	for _, kind := range c.RecreateKinds {
+		if kind == "*" {
+			recreate.All = true
+			continue
+		}
+		recreate.Kinds = append(recreate.Kinds, kind)
+	}
+	return recreate
+}
+
+// notifierOptions returns the notifier.Options configured by these ControllerOptions
+func (c ControllerOptions) notifierOptions() notifier.Options {
+	opts := notifier.Options{
+		ConfigMapName: c.NotifierConfigMap,
+	}
+	for i, url := range c.NotifierWebhookURLs {
+		opts.Sinks = append(opts.Sinks, notifier.NewSinkConfig(fmt.Sprintf("%s-%d", notifier.WebhookSinkType, i), notifier.WebhookSinkType, url))
+	}
+	for i, url := range c.NotifierCloudEventsURLs {
+		opts.Sinks = append(opts.Sinks, notifier.NewSinkConfig(fmt.Sprintf("%s-%d", notifier.CloudEventsSinkType, i), notifier.CloudEventsSinkType, url))
+	}
+	for i, url := range c.NotifierSlackURLs {
+		opts.Sinks = append(opts.Sinks, notifier.NewSinkConfig(fmt.Sprintf("%s-%d", notifier.SlackSinkType, i), notifier.SlackSinkType, url))
+	}
+	return opts
+}